@@ -30,6 +30,25 @@ const showResult = function() {
     }
 }
 
+// placeName returns the name to display for a place (i.e. street and house number for house numbers).
+const placeName = function(p) {
+    return p.class === 'houseNumber' ? p.street + ' ' + p.houseNumber : p.name
+}
+
+// highlightName returns the name of the result's place with the parts matching the input in bold.
+const highlightName = function(result) {
+
+    // spans are given in runes (i.e. code points), not in UTF-16 code units
+    const runes = Array.from(placeName(result.place));
+    let str = '';
+    let pos = 0;
+    for (const span of (result.spans || [])) {
+        str += runes.slice(pos, span.start).join('') + '<strong>' + runes.slice(span.start, span.end).join('') + '</strong>';
+        pos = span.end;
+    }
+    return str + runes.slice(pos).join('');
+}
+
 // annotateDuplicates adds a discriminator string to items with the same name.
 const annotateDuplicates = (arr) => {
 
@@ -46,7 +65,7 @@ const annotateDuplicates = (arr) => {
             const ij = arr[j].place;
 
             // if names are equal
-            if (ii.name === ij.name) {
+            if (placeName(ii) === placeName(ij)) {
 
                 // if districts are equal, we also need postcode to discriminate
                 if (ii.district === ij.district) {
@@ -105,7 +124,7 @@ const myBloodhoundConfiguration = new Bloodhound({
 
     // what part of the results (returned from query) to consider in Bloodhound
     datumTokenizer: function(datum) {
-        return Bloodhound.tokenizers.obj.whitespace(datum.place.name);
+        return Bloodhound.tokenizers.obj.whitespace(placeName(datum.place));
     },
 
     // how to split input to be fed to the query
@@ -117,8 +136,8 @@ const myBloodhoundConfiguration = new Bloodhound({
         wildcard: '%QUERY',
         rateLimitWait: 100,
 
-        // what to do with results before they are fed to Bloodhound
-        filter: annotateDuplicates
+        // what to do with results before they are fed to Bloodhound (postcode entries have no place to select)
+        filter: results => annotateDuplicates(results.filter(result => result.place))
     }
 });
 
@@ -134,7 +153,7 @@ const myTypeaheadDataset = {
     name: 'colors',
 
     // what to show in the input field
-    display: function(item){return item.place.name},
+    display: function(item){return placeName(item.place)},
 
     source: myBloodhoundConfiguration.ttAdapter(),
     limit: 'Infinity', // let the server decide the number of hits
@@ -149,10 +168,12 @@ const myTypeaheadDataset = {
                 } else if (['nightclub', 'pub', 'restaurant', 'house', 'cafe', 'biergarten', 'bar'].includes(data.place.type)) {
                     str += '<i class="bi bi-cup-straw"></i>'
                 }
+            } else if (data.place.class === 'district') {
+                str += '<i class="bi bi-map"></i>'
             } else {
                 str += '<i class="bi bi-geo-alt"></i>'
             }
-            str += data.place.name
+            str += highlightName(data)
             if (data.place.disc !== '') {
                 str += '<span class="sugestion-discriminator">(' + data.place.disc + ')</span>';
             }
@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+type AdminAPI struct {
+
+	// Reload starts (re)loading the data (in the background). Reload returns
+	// false, if a reload is already running (and thus no reload was started).
+	Reload func() bool
+}
+
+// PostReload is the Handler for the /admin/reload-endpoint.
+func (adminAPI AdminAPI) PostReload(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	if !adminAPI.Reload() {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
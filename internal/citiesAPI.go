@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/heimdalr/berlinplaces/pkg/places"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// CitiesAPI serves the datasets of a places.Registry (i.e. /cities/:city/...).
+type CitiesAPI struct {
+	*places.Registry
+}
+
+// placesAPI returns the PlacesAPI of the city given by the request (or writes
+// 404, if the city is unknown).
+func (citiesAPI CitiesAPI) placesAPI(w http.ResponseWriter, ps httprouter.Params) (PlacesAPI, bool) {
+	h, ok := citiesAPI.Holder(ps.ByName("city"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return PlacesAPI{}, false
+	}
+	return PlacesAPI{Holder: h}, true
+}
+
+// GetCities is the Handler for the /cities-endpoint (i.e. the metrics per city).
+func (citiesAPI CitiesAPI) GetCities(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	j, err := json.Marshal(citiesAPI.Metrics())
+	if err != nil {
+		panic(fmt.Errorf("failed to marshall metrics: %w", err))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(j)
+	if err != nil {
+		panic(fmt.Errorf("failed to write response body: %w", err))
+	}
+}
+
+// GetCompletions is the Handler for the /cities/:city/places-endpoint.
+func (citiesAPI CitiesAPI) GetCompletions(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if placesAPI, ok := citiesAPI.placesAPI(w, ps); ok {
+		placesAPI.GetCompletions(w, r, ps)
+	}
+}
+
+// GetPlace is the Handler for the /cities/:city/places/:placeID-endpoint (and,
+// as httprouter doesn't allow "/cities/:city/places/reverse" next to it, for
+// reverse geocoding).
+func (citiesAPI CitiesAPI) GetPlace(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if placesAPI, ok := citiesAPI.placesAPI(w, ps); ok {
+		if ps.ByName("placeID") == "reverse" {
+			placesAPI.GetReverse(w, r, ps)
+			return
+		}
+		placesAPI.GetPlace(w, r, ps)
+	}
+}
+
+// GetHouseNumbers is the Handler for the /cities/:city/places/:placeID/housenumbers-endpoint.
+func (citiesAPI CitiesAPI) GetHouseNumbers(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if placesAPI, ok := citiesAPI.placesAPI(w, ps); ok {
+		placesAPI.GetHouseNumbers(w, r, ps)
+	}
+}
+
+// GetMetrics is the Handler for the /cities/:city/metrics-endpoint.
+func (citiesAPI CitiesAPI) GetMetrics(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if placesAPI, ok := citiesAPI.placesAPI(w, ps); ok {
+		placesAPI.GetMetrics(w, r, ps)
+	}
+}
+
+// GetSearch is the Handler for the /search-endpoint (i.e. completions merged
+// across the given cities or across all cities, if none are given).
+func (citiesAPI CitiesAPI) GetSearch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+
+	// parse the query
+	queryValues := r.URL.Query()
+	query, ok := parseQuery(queryValues)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// get the cities (if any)
+	cities := listValues(queryValues, "city")
+	for _, city := range cities {
+		if _, ok := citiesAPI.Holder(city); !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+
+	// get merged completions
+	results, partial, err := citiesAPI.Registry.GetCompletionsPartial(r.Context(), query, cities)
+	if err != nil {
+		panic(fmt.Errorf("failed to get completions: %w", err))
+	}
+
+	// encode completions
+	j, err := json.Marshal(results)
+	if err != nil {
+		panic(fmt.Errorf("failed to marshall results: %w", err))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if partial {
+		w.Header().Set(partialHeader, "true")
+	}
+	_, err = w.Write(j)
+	if err != nil {
+		panic(fmt.Errorf("failed to write response body: %w", err))
+	}
+}
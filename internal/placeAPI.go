@@ -1,31 +1,41 @@
 package internal
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/heimdalr/berlinplaces/pkg/places"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
+// maxReverseCount is the maximum number of places to return via reverse geocoding.
+const maxReverseCount = 100
+
+// maxCompletionLimit is the maximum number of completions to return per page.
+const maxCompletionLimit = 100
+
+// partialHeader is the header flagging partial completions (i.e. completions
+// computed beyond the latency budget), even if there are none.
+const partialHeader = "X-Partial"
+
 type PlacesAPI struct {
-	*places.Places
+	*places.Holder
 }
 
 func (placesAPI PlacesAPI) GetCompletions(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 
-	// get the search text from the request
-	queryValues := r.URL.Query()
-	text := queryValues.Get("text")
-	if text == "" {
+	// parse the query
+	query, ok := parseQuery(r.URL.Query())
+	if !ok {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
 	// get completions
-	results := placesAPI.Places.GetCompletions(context.Background(), text)
+	results, partial := placesAPI.Places().GetCompletionsPartial(r.Context(), query)
 
 	// encode completions
 	j, err := json.Marshal(results)
@@ -33,6 +43,9 @@ func (placesAPI PlacesAPI) GetCompletions(w http.ResponseWriter, r *http.Request
 		panic(fmt.Errorf("failed to marshall results: %w", err))
 	}
 	w.Header().Set("Content-Type", "application/json")
+	if partial {
+		w.Header().Set(partialHeader, "true")
+	}
 	_, err = w.Write(j)
 	if err != nil {
 		panic(fmt.Errorf("failed to write response body: %w", err))
@@ -61,7 +74,7 @@ func (placesAPI PlacesAPI) GetPlace(w http.ResponseWriter, r *http.Request, ps h
 	houseNumber := queryValues.Get("houseNumber")
 
 	// get the place
-	p := placesAPI.Places.GetPlace(context.Background(), placeID, houseNumber)
+	p := placesAPI.Places().GetPlace(r.Context(), placeID, houseNumber)
 	if p == nil {
 		w.WriteHeader(http.StatusNotFound)
 		return
@@ -79,8 +92,37 @@ func (placesAPI PlacesAPI) GetPlace(w http.ResponseWriter, r *http.Request, ps h
 	}
 }
 
+// GetHouseNumbers is the Handler for the /places/:placeID/housenumbers-endpoint.
+func (placesAPI PlacesAPI) GetHouseNumbers(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	// parse the place ID
+	placeID, err := strconv.ParseInt(ps.ByName("placeID"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// get the house numbers
+	houses := placesAPI.Places().CompleteHouseNumber(r.Context(), placeID, r.URL.Query().Get("prefix"))
+	if houses == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// encode house numbers
+	j, err := json.Marshal(houses)
+	if err != nil {
+		panic(fmt.Errorf("failed to marshall house numbers: %w", err))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(j)
+	if err != nil {
+		panic(fmt.Errorf("failed to write response body: %w", err))
+	}
+}
+
 func (placesAPI PlacesAPI) GetMetrics(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
-	m := placesAPI.Places.Metrics()
+	m := placesAPI.Places().Metrics()
 	j, err := json.Marshal(m)
 	if err != nil {
 		panic(fmt.Errorf("failed to marshall metrics: %w", err))
@@ -91,3 +133,117 @@ func (placesAPI PlacesAPI) GetMetrics(w http.ResponseWriter, _ *http.Request, _
 		panic(fmt.Errorf("failed to write response body: %w", err))
 	}
 }
+
+// GetReverse is the Handler for reverse geocoding (i.e. /places/reverse).
+func (placesAPI PlacesAPI) GetReverse(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+
+	// parse the coordinates
+	queryValues := r.URL.Query()
+	lat, errLat := strconv.ParseFloat(queryValues.Get("lat"), 64)
+	lon, errLon := strconv.ParseFloat(queryValues.Get("lon"), 64)
+	if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// parse the number of places to return (if any)
+	k := 1
+	if kStr := queryValues.Get("k"); kStr != "" {
+		i, err := strconv.Atoi(kStr)
+		if err != nil || i < 1 || i > maxReverseCount {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		k = i
+	}
+
+	// get the closest places
+	results := placesAPI.Places().Reverse(r.Context(), lat, lon, k)
+
+	// encode results
+	j, err := json.Marshal(results)
+	if err != nil {
+		panic(fmt.Errorf("failed to marshall results: %w", err))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(j)
+	if err != nil {
+		panic(fmt.Errorf("failed to write response body: %w", err))
+	}
+}
+
+// parseQuery parses the completion query from the given query parameters (ok
+// is false, if the parameters are invalid).
+func parseQuery(queryValues url.Values) (query places.Query, ok bool) {
+
+	// get the search text
+	text := queryValues.Get("text")
+	if text == "" {
+		return query, false
+	}
+	query.Text = text
+
+	// get the reference point (if any)
+	latStr, lonStr := queryValues.Get("lat"), queryValues.Get("lon")
+	if latStr != "" || lonStr != "" {
+		lat, errLat := strconv.ParseFloat(latStr, 64)
+		lon, errLon := strconv.ParseFloat(lonStr, 64)
+		if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return query, false
+		}
+		query.Near = &places.Point{Lat: lat, Lon: lon}
+	}
+
+	// get the bias strength (if any)
+	if biasStr := queryValues.Get("bias"); biasStr != "" {
+		bias, err := strconv.ParseFloat(biasStr, 64)
+		if err != nil || bias < 0 {
+			return query, false
+		}
+		query.Bias = &bias
+	}
+
+	// get the restrictions (if any)
+	for _, className := range listValues(queryValues, "class") {
+		class, err := places.ParseClass(className)
+		if err != nil {
+			return query, false
+		}
+		query.Classes = append(query.Classes, class)
+	}
+	query.Types = listValues(queryValues, "type")
+	query.Districts = listValues(queryValues, "district")
+	query.Postcodes = listValues(queryValues, "postcode")
+
+	// get the page (if any)
+	if limitStr := queryValues.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxCompletionLimit {
+			return query, false
+		}
+		query.Limit = limit
+	}
+	if offsetStr := queryValues.Get("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return query, false
+		}
+		query.Offset = offset
+	}
+
+	return query, true
+}
+
+// listValues returns all values of the given query parameter (which may be
+// repeated and / or contain comma separated values).
+func listValues(values url.Values, key string) []string {
+	var list []string
+	for _, value := range values[key] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+	}
+	return list
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/fsnotify/fsnotify"
 	"github.com/heimdalr/berlinplaces/internal"
 	"github.com/heimdalr/berlinplaces/pkg/data"
 	"github.com/heimdalr/berlinplaces/pkg/places"
@@ -10,11 +12,15 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
+	"io/fs"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime/debug"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -24,9 +30,35 @@ const viperEnvPrefix = "PLACES"
 var buildVersion = "to be set by linker"
 var buildGitHash = "to be set by linker"
 
+// watchDelay is the duration to wait for changes to the places CSV file to settle before reloading.
+const watchDelay = 2 * time.Second
+
 // The application type.
 type application struct {
 	http.Server
+
+	// the datasets (the first one being the default dataset)
+	datasets []*dataset
+
+	// the datasets by name
+	registry *places.Registry
+
+	// 1 while reloading via the admin API (see tryReload)
+	reloading int32
+}
+
+// A dataset (e.g. the places of a city) and the files it is read from.
+type dataset struct {
+	name              string
+	districtsFileName string
+	placesFileName    string
+	relevanceFileName string
+
+	// the (reloadable) places
+	places *places.Holder
+
+	// serializes reloads
+	reloadMutex sync.Mutex
 }
 
 // main.
@@ -49,6 +81,9 @@ func main() {
 		Str("port", viper.GetString("PORT")).
 		Bool("spec", viper.GetBool("SPEC")).
 		Bool("demo", viper.GetBool("DEMO")).
+		Bool("admin", viper.GetBool("ADMIN")).
+		Bool("watch", viper.GetBool("WATCH")).
+		Strs("datasets", datasetNames()).
 		Msg("config")
 
 	// initialize the app
@@ -61,6 +96,15 @@ func main() {
 	// run the app
 	app.run()
 
+	// reload places on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			app.reloadAll("SIGHUP")
+		}
+	}()
+
 	// wait for an interrupt
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
@@ -77,6 +121,14 @@ func viperSetup() {
 	viper.SetEnvPrefix(viperEnvPrefix)
 	viper.AutomaticEnv()
 
+	// read the config file (if any), env variables take precedence over it
+	if configFile := os.Getenv(viperEnvPrefix + "_CONFIG_FILE"); configFile != "" {
+		viper.SetConfigFile(configFile)
+		if err := viper.ReadInConfig(); err != nil {
+			log.Fatal().Err(err).Str("file", configFile).Msg("failed to read config file")
+		}
+	}
+
 	// default values
 	viper.SetDefault("DEBUG", true)
 	viper.SetDefault("PORT", "8080")
@@ -88,9 +140,24 @@ func viperSetup() {
 	viper.SetDefault("MIN_LEV", c.MinLev)
 	viper.SetDefault("DISTANCE_CUT", c.DistanceCut)
 	viper.SetDefault("CACHE_TTL", c.CacheTTL)
-
+	viper.SetDefault("PROXIMITY_BIAS", c.ProximityBias)
+	viper.SetDefault("TOKEN_MATCHING", c.TokenMatching)
+	viper.SetDefault("FUZZY_DISTANCE", c.FuzzyDistance)
+	viper.SetDefault("INFIX_MATCHING", c.InfixMatching)
+	viper.SetDefault("PAGINATION_TTL", c.PaginationTTL)
+	viper.SetDefault("LATENCY_BUDGET", c.LatencyBudget)
+	viper.SetDefault("RELEVANCE_HALF_LIFE", c.RelevanceHalfLife)
+	viper.SetDefault("DISTANCE_METRIC", string(c.DistanceMetric))
+	viper.SetDefault("RANKING_WEIGHTS", "") // e.g. "location=5,distance=-2" (see places.ParseRankingWeights)
+
+	// the datasets to serve, files of datasets are configured via <NAME>_DISTRICTS_CSV,
+	// <NAME>_PLACES_CSV and <NAME>_RELEVANCE_FILE (the first one defaults to the following)
+	viper.SetDefault("DATASETS", "berlin")
 	viper.SetDefault("DISTRICTS_CSV", "_data/districts.csv") // relative to project root
 	viper.SetDefault("PLACES_CSV", "_data/places.csv")
+	viper.SetDefault("WATCH", false)       // reload places when places CSV files change
+	viper.SetDefault("RELEVANCE_FILE", "") // the file to persist relevance in (if any)
+	viper.SetDefault("RELEVANCE_INTERVAL", 5*time.Minute)
 
 	// set defaults for whether to enable swagger-docs depending on DEBUG
 	if viper.GetBool("DEBUG") {
@@ -106,6 +173,9 @@ func viperSetup() {
 		viper.SetDefault("DEMO", false)
 	}
 
+	// admin endpoints are not authenticated, thus they must be enabled explicitly
+	viper.SetDefault("ADMIN", false)
+
 }
 
 // initialize the application.
@@ -134,78 +204,310 @@ func (app *application) initialize() error {
 		})
 	}
 
+	// places configuration
+	placesConfig := places.Config{
+		MaxPrefixLength:    viper.GetInt("MAX_PREFIX_LENGTH"),
+		MinCompletionCount: viper.GetInt("MIN_COMPLETION_COUNT"),
+		MinLev:             viper.GetInt("MIN_LEV"),
+		DistanceCut:        viper.GetInt("DISTANCE_CUT"),
+		CacheTTL:           viper.GetDuration("CACHE_TTL"),
+		ProximityBias:      viper.GetFloat64("PROXIMITY_BIAS"),
+		TokenMatching:      viper.GetBool("TOKEN_MATCHING"),
+		FuzzyDistance:      viper.GetInt("FUZZY_DISTANCE"),
+		InfixMatching:      viper.GetBool("INFIX_MATCHING"),
+		PaginationTTL:      viper.GetDuration("PAGINATION_TTL"),
+		LatencyBudget:      viper.GetDuration("LATENCY_BUDGET"),
+		RelevanceHalfLife:  viper.GetDuration("RELEVANCE_HALF_LIFE"),
+		DistanceMetric:     places.DistanceMetric(viper.GetString("DISTANCE_METRIC")),
+	}
+
+	// use the weighted ranker (if weights are given)
+	if rankingWeights := viper.GetString("RANKING_WEIGHTS"); rankingWeights != "" {
+		weights, err := places.ParseRankingWeights(rankingWeights)
+		if err != nil {
+			return fmt.Errorf("failed to parse ranking weights: %w", err)
+		}
+		placesConfig.RankingWeights = weights
+	}
+
+	// initialize datasets
+	app.registry = places.NewRegistry()
+	for i, name := range datasetNames() {
+		d := &dataset{
+			name:              name,
+			districtsFileName: datasetString(name, "DISTRICTS_CSV", i == 0),
+			placesFileName:    datasetString(name, "PLACES_CSV", i == 0),
+			relevanceFileName: datasetString(name, "RELEVANCE_FILE", i == 0),
+		}
+		if err := d.initialize(placesConfig); err != nil {
+			return fmt.Errorf("failed to initialize dataset '%s': %w", name, err)
+		}
+		if err := app.registry.Add(name, d.places); err != nil {
+			return err
+		}
+		app.datasets = append(app.datasets, d)
+	}
+	if len(app.datasets) == 0 {
+		return fmt.Errorf("no datasets configured")
+	}
+
+	// register places routes (of the default dataset)
+	placesAPI := internal.PlacesAPI{Holder: app.datasets[0].places}
+	router.GET("/places", placesAPI.GetCompletions)
+	router.GET("/places/:placeID", placesAPI.GetPlace)
+	router.GET("/places/:placeID/housenumbers", placesAPI.GetHouseNumbers)
+	router.GET("/metrics", placesAPI.GetMetrics)
+
+	// register cities routes (i.e. of all datasets)
+	citiesAPI := internal.CitiesAPI{Registry: app.registry}
+	router.GET("/cities", citiesAPI.GetCities)
+	router.GET("/cities/:city/places", citiesAPI.GetCompletions)
+	router.GET("/cities/:city/places/:placeID", citiesAPI.GetPlace)
+	router.GET("/cities/:city/places/:placeID/housenumbers", citiesAPI.GetHouseNumbers)
+	router.GET("/cities/:city/metrics", citiesAPI.GetMetrics)
+	router.GET("/search", citiesAPI.GetSearch)
+
+	// register admin routes (if desired)
+	if viper.GetBool("ADMIN") {
+		adminAPI := internal.AdminAPI{Reload: func() bool { return app.tryReload("admin") }}
+		router.POST("/admin/reload", adminAPI.PostReload)
+	}
+
+	// reload places when places CSV files change (if desired)
+	if viper.GetBool("WATCH") {
+		if err := app.watchPlaces(); err != nil {
+			return fmt.Errorf("failed to watch places: %w", err)
+		}
+	}
+
+	// version
+	versionAPI := internal.VersionAPI{Version: buildVersion, Hash: buildGitHash}
+	router.GET("/version", versionAPI.GetVersion)
+
+	// httprouter doesn't allow "/places/reverse" next to "/places/:placeID", thus serve it via a mux in front
+	mux := http.NewServeMux()
+	mux.Handle("/places/reverse", getHandler(router, placesAPI.GetReverse))
+	mux.Handle("/", router)
+
+	// wrap the mux into a logging middleware
+	loggingRouter := internal.LoggerMiddleware{Handler: mux, Logger: log.Logger}
+
+	// setup HTTP server
+	app.Server = http.Server{
+		Addr:           fmt.Sprintf(":%s", viper.GetString("PORT")),
+		Handler:        loggingRouter,
+		ReadTimeout:    10 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
+
+	return nil
+}
+
+// getHandler adapts the given handle to a http.Handler serving GET requests
+// only (with panics handled by the panic handler of the given router).
+func getHandler(router *httprouter.Router, handle httprouter.Handle) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				router.PanicHandler(w, r, rcv)
+			}
+		}()
+		handle(w, r, nil)
+	})
+}
+
+// datasetNames returns the names of the configured datasets.
+func datasetNames() []string {
+	var names []string
+	for _, name := range strings.Split(viper.GetString("DATASETS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// datasetString returns the value of the given key for the named dataset (i.e. of
+// <NAME>_<KEY>), falling back to the value of the key itself (if desired).
+func datasetString(name, key string, fallback bool) string {
+	datasetKey := strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_" + key
+	if value := viper.GetString(datasetKey); value != "" || !fallback {
+		return value
+	}
+	return viper.GetString(key)
+}
+
+// initialize the dataset (i.e. load places and restore relevance).
+func (d *dataset) initialize(placesConfig places.Config) error {
+
+	// load places
+	err := d.withDataProvider(func(dataProvider places.Provider) error {
+		holder, err := placesConfig.NewHolder(dataProvider)
+		d.places = holder
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize places: %w", err)
+	}
+
+	// log basic stats about places
+	metrics := d.places.Places().Metrics()
+	log.Info().
+		Str("dataset", d.name).
+		Int32("streetCount", metrics.StreetCount).
+		Int32("locationCount", metrics.LocationCount).
+		Int32("districtCount", metrics.DistrictCount).
+		Int32("houseNumberCount", metrics.HouseNumberCount).
+		Int("prefixCount", metrics.PrefixCount).
+		Msg("places")
+
+	// restore relevance (if any)
+	if d.relevanceFileName != "" {
+		err := d.places.Places().LoadRelevance(d.relevanceFileName)
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Info().Str("dataset", d.name).Str("file", d.relevanceFileName).Msg("no relevance to restore")
+		} else if err != nil {
+			return fmt.Errorf("failed to restore relevance: %w", err)
+		} else {
+			log.Info().Str("dataset", d.name).Str("file", d.relevanceFileName).Msg("relevance restored")
+		}
+	}
+
+	return nil
+}
+
+// withDataProvider opens (and closes) the CSV files of the dataset and calls f with a data provider reading them.
+func (d *dataset) withDataProvider(f func(dataProvider places.Provider) error) error {
+
 	// open (close) districts CSV file
-	districtsFileName := viper.GetString("DISTRICTS_CSV")
-	districtsReader, err := os.Open(districtsFileName)
+	districtsReader, err := os.Open(d.districtsFileName)
 	if err != nil {
-		return fmt.Errorf("failed to open '%s': %w", districtsFileName, err)
+		return fmt.Errorf("failed to open '%s': %w", d.districtsFileName, err)
 	}
 	defer func(file *os.File) {
 		_ = file.Close()
 	}(districtsReader)
 
 	// open (close) places CSV file
-	placesFileName := viper.GetString("PLACES_CSV")
-	placesReader, err := os.Open(placesFileName)
+	placesReader, err := os.Open(d.placesFileName)
 	if err != nil {
-		return fmt.Errorf("failed to open '%s': %w", placesFileName, err)
+		return fmt.Errorf("failed to open '%s': %w", d.placesFileName, err)
 	}
 	defer func(file *os.File) {
 		_ = file.Close()
 	}(placesReader)
 
-	// init the data provider
-	dataProvider := data.CSVProvider{
+	// pass the data provider
+	return f(data.CSVProvider{
 		DistrictsReader: districtsReader,
 		PlacesReader:    placesReader,
-	}
-
-	// places configuration
-	placesConfig := places.Config{
-		MaxPrefixLength:    viper.GetInt("MAX_PREFIX_LENGTH"),
-		MinCompletionCount: viper.GetInt("MIN_COMPLETION_COUNT"),
-		MinLev:             viper.GetInt("MIN_LEV"),
-		DistanceCut:        viper.GetInt("DISTANCE_CUT"),
-		CacheTTL:           viper.GetDuration("CACHE_TTL"),
-	}
-
-	// initialize (berlin) places
+	})
+}
 
-	p, err := placesConfig.NewPlaces(dataProvider)
+// reload reloads places (in the background, i.e. places are served until the new ones are ready). Reloads of the
+// same dataset are serialized.
+func (d *dataset) reload(trigger string) {
+	d.reloadMutex.Lock()
+	defer d.reloadMutex.Unlock()
+
+	log.Info().Str("dataset", d.name).Str("trigger", trigger).Msg("reloading places")
+	start := time.Now()
+	err := d.withDataProvider(func(dataProvider places.Provider) error {
+		return d.places.Reload(dataProvider)
+	})
 	if err != nil {
-		panic(fmt.Errorf("failed to initialize places: %w", err))
+		log.Error().Err(err).Str("dataset", d.name).Msg("failed to reload places")
+		return
 	}
 
 	// log basic stats about places
-	metrics := p.Metrics()
+	metrics := d.places.Places().Metrics()
 	log.Info().
+		Str("dataset", d.name).
 		Int32("streetCount", metrics.StreetCount).
 		Int32("locationCount", metrics.LocationCount).
+		Int32("districtCount", metrics.DistrictCount).
 		Int32("houseNumberCount", metrics.HouseNumberCount).
 		Int("prefixCount", metrics.PrefixCount).
-		Msg("places")
+		Dur("duration", time.Since(start)).
+		Msg("places reloaded")
+}
 
-	// register places routes
-	placesAPI := internal.PlacesAPI{Places: p}
-	router.GET("/places", placesAPI.GetCompletions)
-	router.GET("/places/:placeID", placesAPI.GetPlace)
-	router.GET("/metrics", placesAPI.GetMetrics)
+// reloadAll reloads the places of all datasets.
+func (app *application) reloadAll(trigger string) {
+	for _, d := range app.datasets {
+		d.reload(trigger)
+	}
+}
 
-	// version
-	versionAPI := internal.VersionAPI{Version: buildVersion, Hash: buildGitHash}
-	router.GET("/version", versionAPI.GetVersion)
+// tryReload reloads the places of all datasets in the background, unless such a reload is already running (in which
+// case tryReload returns false).
+func (app *application) tryReload(trigger string) bool {
+	if !atomic.CompareAndSwapInt32(&app.reloading, 0, 1) {
+		return false
+	}
+	go func() {
+		defer atomic.StoreInt32(&app.reloading, 0)
+		app.reloadAll(trigger)
+	}()
+	return true
+}
 
-	// wrap the router into a logging middleware
-	loggingRouter := internal.LoggerMiddleware{Handler: router, Logger: log.Logger}
+// watchPlaces reloads the places of a dataset whenever its places CSV file changes.
+func (app *application) watchPlaces() error {
 
-	// setup HTTP server
-	app.Server = http.Server{
-		Addr:           fmt.Sprintf(":%s", viper.GetString("PORT")),
-		Handler:        loggingRouter,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
+	// watch the directories (as files are often replaced rather than written)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
 	}
+	datasetsByFile := make(map[string]*dataset)
+	for _, d := range app.datasets {
+		fileName := filepath.Clean(d.placesFileName)
+		datasetsByFile[fileName] = d
+		if err := watcher.Add(filepath.Dir(fileName)); err != nil {
+			_ = watcher.Close()
+			return err
+		}
+	}
+
+	go func() {
+		defer func() {
+			_ = watcher.Close()
+		}()
+		timers := make(map[*dataset]*time.Timer)
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				d, watched := datasetsByFile[filepath.Clean(event.Name)]
+				if !watched || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+
+				// wait for changes to settle
+				if timer := timers[d]; timer != nil {
+					timer.Stop()
+				}
+				timers[d] = time.AfterFunc(watchDelay, func() {
+					d.reload("watch")
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error().Err(err).Msg("failed to watch places")
+			}
+		}
+	}()
 
 	return nil
 }
@@ -221,6 +523,39 @@ func (app *application) run() {
 	}()
 
 	log.Info().Msgf("listening on http://localhost:%s", strings.TrimLeft(app.Server.Addr, ":"))
+
+	// periodically persist relevance (if desired)
+	if app.persistsRelevance() {
+		go func() {
+			for range time.Tick(viper.GetDuration("RELEVANCE_INTERVAL")) {
+				app.saveRelevance()
+			}
+		}()
+	}
+}
+
+// persistsRelevance returns true, if relevance of any dataset is to be persisted.
+func (app *application) persistsRelevance() bool {
+	for _, d := range app.datasets {
+		if d.relevanceFileName != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// saveRelevance persists relevance of all datasets (if desired).
+func (app *application) saveRelevance() {
+	for _, d := range app.datasets {
+		if d.relevanceFileName == "" {
+			continue
+		}
+		if err := d.places.Places().SaveRelevance(d.relevanceFileName); err != nil {
+			log.Error().Err(err).Str("dataset", d.name).Msg("failed to persist relevance")
+			continue
+		}
+		log.Debug().Str("dataset", d.name).Str("file", d.relevanceFileName).Msg("relevance persisted")
+	}
 }
 
 // shutdown shuts the application down.
@@ -233,4 +568,7 @@ func (app *application) shutdown() {
 	if err := app.Server.Shutdown(ctx); err != nil {
 		log.Error().Err(err).Msg("server shutdown failed")
 	}
+
+	// persist relevance a final time
+	app.saveRelevance()
 }
@@ -55,9 +55,15 @@ func (provider CSVProvider) Get() (places.DistrictMap, places.PlaceMap, *places.
 	counts := make(map[places.Class]int32)
 	placesChan := make(chan CSVPlace)
 	placesDoneChan := make(chan bool)
+	var errPlaces error
 	go func() {
 		for csvPlace := range placesChan {
 
+			// after an error, drain the channel
+			if errPlaces != nil {
+				continue
+			}
+
 			place := places.Place{}
 
 			place.ID = csvPlace.ID
@@ -67,14 +73,18 @@ func (provider CSVProvider) Get() (places.DistrictMap, places.PlaceMap, *places.
 			if place.Class == places.LocationClass || place.Class == places.HouseNumberClass {
 				street, exists := placeMap[csvPlace.StreetID]
 				if !exists {
-					panic(fmt.Errorf("a street (place) with the id '%d' does not yet exist", csvPlace.StreetID))
+					errPlaces = fmt.Errorf("a street (place) with the id '%d' does not yet exist", csvPlace.StreetID)
+					continue
 				}
 				place.Street = street
 			}
-			place.HouseNumber = csvPlace.HouseNumber
+			if csvPlace.HouseNumber != "" {
+				place.HouseNumber = places.NormalizeHouseNumber(csvPlace.HouseNumber)
+			}
 			district, exists := districtsMap[csvPlace.Postcode]
 			if !exists {
-				panic(fmt.Errorf("a district (postcode) with the id '%s' does not exist", csvPlace.Postcode))
+				errPlaces = fmt.Errorf("a district (postcode) with the id '%s' does not exist", csvPlace.Postcode)
+				continue
 			}
 			place.District = district
 			if place.Class == places.StreetClass && csvPlace.Length > 0 {
@@ -85,6 +95,7 @@ func (provider CSVProvider) Get() (places.DistrictMap, places.PlaceMap, *places.
 			if place.Class == places.StreetClass || place.Class == places.LocationClass {
 				simpleName := places.SanitizeString(csvPlace.Name)
 				place.SimpleName = simpleName
+				place.Phonetic = places.ColognePhonetic(csvPlace.Name)
 			}
 			if place.Class == places.HouseNumberClass {
 				place.Street.HouseNumbers = append(place.Street.HouseNumbers, &place)
@@ -100,6 +111,9 @@ func (provider CSVProvider) Get() (places.DistrictMap, places.PlaceMap, *places.
 		return nil, nil, nil, err
 	}
 	<-placesDoneChan
+	if errPlaces != nil {
+		return nil, nil, nil, errPlaces
+	}
 
 	metrics := places.Metrics{
 		StreetCount:      counts[places.StreetClass],
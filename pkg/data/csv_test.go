@@ -50,3 +50,40 @@ func TestCSVProvider_Get(t *testing.T) {
 	}
 
 }
+
+func TestCSVProvider_GetUnknownPostcode(t *testing.T) {
+	p := CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader: strings.NewReader(`
+id,type,name,street_id,house_number,postcode,length,lat,lon
+1,,Elisabeth-Feller-Weg,,,99999,10,52.51121427531362,13.433862108201659
+`),
+	}
+	if _, _, _, err := p.Get(); err == nil {
+		t.Errorf("Got no error, want one")
+	}
+}
+
+func TestCSVProvider_GetHouseNumbers(t *testing.T) {
+	p := CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader: strings.NewReader(`
+id,type,name,street_id,house_number,postcode,length,lat,lon
+1,,Elisabeth-Feller-Weg,,,12524,10,52.51121427531362,13.433862108201659
+8589934593,,,1,3 A,12524,,52.4127212,13.5714066
+8589934594,,,1,5 - 7,12524,,52.4128,13.5715
+8589934595,,,1,12/14,12524,,52.4129,13.5716
+`),
+	}
+	_, placesMap, _, err := p.Get()
+	if err != nil {
+		t.Fatalf("Got error = %v", err)
+	}
+
+	// house numbers are normalized
+	for id, want := range map[int64]string{8589934593: "3a", 8589934594: "5-7", 8589934595: "12,14"} {
+		if got := placesMap[id].HouseNumber; got != want {
+			t.Errorf("Got house number %s, want %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package places
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	// postcodeRegexp matches (German) postcodes.
+	postcodeRegexp = regexp.MustCompile(`^[0-9]{5}$`)
+
+	// houseNumberRegexp matches house numbers (with an optional letter suffix) and
+	// ranges or lists thereof (e.g. "3-5" or "12/14").
+	houseNumberRegexp = regexp.MustCompile(`^[0-9]{1,4}[a-zA-Z]?([-/][0-9]{0,4}[a-zA-Z]?)*$`)
+
+	// suffixRegexp matches letter suffixes of house numbers (e.g. the "a" in "12 a").
+	suffixRegexp = regexp.MustCompile(`^[a-zA-Z]$`)
+)
+
+// Address is a (free text) address dissected into its parts.
+type Address struct {
+
+	// Street is the street- or location part of the address.
+	Street string
+
+	// HouseNumber is the house number (if any).
+	HouseNumber string
+
+	// Postcode is the postcode (if any).
+	Postcode string
+}
+
+// ParseAddress dissects the given input into street (or location), house number
+// and postcode. A house number is only recognized if it follows the street part.
+// Tokens following the house number (e.g. the city) are ignored.
+func ParseAddress(input string) Address {
+	return parseAddress(input, nil)
+}
+
+// parseAddress implements ParseAddress. If isName is given, numbers continuing
+// the street part to (a prefix of) a known name are considered part of the
+// street part (e.g. the "17" in "Straße des 17 Juni") rather than house numbers.
+func parseAddress(input string, isName func(simpleName string) bool) Address {
+
+	// split the input at white spaces and commas
+	tokens := strings.FieldsFunc(input, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ','
+	})
+
+	var address Address
+	var street []string
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+
+		// postcodes may appear anywhere
+		if address.Postcode == "" && postcodeRegexp.MatchString(token) {
+			address.Postcode = token
+			continue
+		}
+
+		// ignore everything following the house number
+		if address.HouseNumber != "" {
+			continue
+		}
+
+		// a house number must follow the street part (and must not continue a name)
+		if len(street) > 0 && houseNumberRegexp.MatchString(token) &&
+			(isName == nil || !isName(SanitizeString(strings.Join(street, " ")+token))) {
+
+			// join a separated letter suffix (e.g. "12 a")
+			if i+1 < len(tokens) && suffixRegexp.MatchString(tokens[i+1]) {
+				token += tokens[i+1]
+				i += 1
+			}
+			address.HouseNumber = NormalizeHouseNumber(token)
+			continue
+		}
+
+		street = append(street, token)
+	}
+	address.Street = strings.Join(street, " ")
+
+	return address
+}
+
+// houseNumberCompletions returns house numbers (and locations with addresses)
+// of the streets matching the street part of the given address. That is, the
+// exactly matching house numbers (including ranges containing the given house
+// number) or, if there are none, house numbers starting with the given house
+// number. If there are neither, the matching streets are returned.
+// Results are ranked by postcode agreement, distance and house number.
+func (bp *Places) houseNumberCompletions(ctx context.Context, address Address, query Query) []*Result {
+
+	// complete the street part (restrictions are applied to house numbers, not to streets)
+	streetQuery := query
+	streetQuery.Text = address.Street
+	streetQuery.Classes = []Class{StreetClass}
+	streetQuery.Types = nil
+	streetQuery.Districts = nil
+	streetQuery.Postcodes = nil
+	streetResults := bp.getCompletions(ctx, streetQuery)
+
+	// collect matching house numbers (i.e. equal ones and ranges containing the house number) and locations of the
+	// streets found (without spans, as the input matched the street name and not theirs)
+	parsed, _ := ParseHouseNumber(address.HouseNumber)
+	var exact, prefixed []*Result
+	streetRanks := make(map[*Place]int)
+	for rank, sr := range streetResults {
+		streetRanks[sr.Place] = rank
+		for _, houses := range [][]*Place{sr.Place.HouseNumbers, sr.Place.locations} {
+			for _, house := range houses {
+				if !query.matches(house) {
+					continue
+				}
+				houseNumber := strings.ToLower(house.HouseNumber)
+				if houseNumber == address.HouseNumber || house.houseNumber.Contains(parsed) {
+					exact = append(exact, &Result{Distance: sr.Distance, Place: house, Match: sr.Match, Partial: sr.Partial})
+				} else if strings.HasPrefix(houseNumber, address.HouseNumber) {
+					distance := sr.Distance + len(houseNumber) - len(address.HouseNumber)
+					prefixed = append(prefixed, &Result{Distance: distance, Place: house, Match: sr.Match, Partial: sr.Partial})
+				}
+			}
+		}
+	}
+	results := exact
+	if len(results) == 0 {
+		results = prefixed
+	}
+
+	// if the house number is unknown, fall back to the streets (satisfying the restrictions)
+	if len(results) == 0 {
+		for _, sr := range streetResults {
+			if query.matches(sr.Place) {
+				results = append(results, sr)
+			}
+		}
+
+		// rank streets in the postcode (if any) first
+		if address.Postcode != "" {
+			sort.SliceStable(results, func(i, j int) bool {
+				pi, pj := results[i].Place, results[j].Place
+				ai := pi.District != nil && pi.District.Postcode == address.Postcode
+				aj := pj.District != nil && pj.District.Postcode == address.Postcode
+				return ai && !aj
+			})
+		}
+		return results
+	}
+
+	// compute distances wrt. the reference point (if any)
+	if query.Near != nil {
+		for _, r := range results {
+			geoDistance := Haversine(query.Near.Lat, query.Near.Lon, r.Place.Lat, r.Place.Lon)
+			r.GeoDistance = &geoDistance
+		}
+	}
+
+	// rank by postcode agreement, distance, rank of the street and house number (house numbers before locations)
+	sort.SliceStable(results, func(i, j int) bool {
+		pi, pj := results[i].Place, results[j].Place
+		if address.Postcode != "" {
+			ai := pi.District != nil && pi.District.Postcode == address.Postcode
+			aj := pj.District != nil && pj.District.Postcode == address.Postcode
+			if ai != aj {
+				return ai
+			}
+		}
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		if streetRanks[pi.Street] != streetRanks[pj.Street] {
+			return streetRanks[pi.Street] < streetRanks[pj.Street]
+		}
+		if len(pi.HouseNumber) != len(pj.HouseNumber) {
+			return len(pi.HouseNumber) < len(pj.HouseNumber)
+		}
+		if pi.HouseNumber != pj.HouseNumber {
+			return pi.HouseNumber < pj.HouseNumber
+		}
+		if pi.Class != pj.Class {
+			return pi.Class == HouseNumberClass
+		}
+		return pi.ID < pj.ID
+	})
+
+	if query.paginated() {
+		return results[:Min(maxRankingLength, len(results))]
+	}
+	return results[:Min(bp.config.MinCompletionCount, len(results))]
+}
+
+// postcodeAddressCompletions returns the completions of the street part of the
+// given address (without house number) in the postcode of the address, if the
+// best of them matches as well as the best completion regardless of the postcode.
+// Otherwise (e.g. in case of a wrong postcode), it returns the latter.
+func (bp *Places) postcodeAddressCompletions(ctx context.Context, address Address, query Query) []*Result {
+	streetQuery := query
+	streetQuery.Text = address.Street
+	results := bp.getCompletions(ctx, streetQuery)
+	if postcodeQuery, satisfiable := streetQuery.withPostcode(address.Postcode); satisfiable {
+		inPostcode := bp.getCompletions(ctx, postcodeQuery)
+		if len(inPostcode) > 0 && (len(results) == 0 || inPostcode[0].Distance <= results[0].Distance) {
+			return inPostcode
+		}
+	}
+	return results
+}
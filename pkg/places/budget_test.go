@@ -0,0 +1,54 @@
+package places_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/heimdalr/berlinplaces/pkg/places"
+	"testing"
+	"time"
+)
+
+func TestPlaces_LatencyBudget(t *testing.T) {
+
+	config := *places.DefaultConfig
+	config.LatencyBudget = time.Nanosecond
+	p, err := config.NewPlaces(syntheticProvider{})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	// exceeding the budget degrades to (partial) prefix matches
+	r := p.GetCompletions(context.Background(), places.Query{Text: "Oranienburger"})
+	if len(r) == 0 {
+		t.Fatalf("got %d, want > 0", len(r))
+	}
+	for _, result := range r {
+		if !result.Partial || result.Match != places.PrefixMatch {
+			t.Errorf("got %s (partial: %t, match: %s), want partial prefix match",
+				result.Place.Name, result.Partial, result.Match)
+		}
+	}
+
+	// exceeding the budget without prefix matches is reported as partial (though there are no results to flag)
+	r, partial := p.GetCompletionsPartial(context.Background(), places.Query{Text: "Oanienburger"})
+	if len(r) != 0 || !partial {
+		t.Errorf("got %d (partial: %t), want 0 (partial)", len(r), partial)
+	}
+
+	// canceled lookups return no results
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r, partial := p.GetCompletionsPartial(ctx, places.Query{Text: "Oanienburger"}); len(r) != 0 || partial {
+		t.Errorf("got %d (partial: %t), want 0", len(r), partial)
+	}
+
+	// lookups within the budget aren't partial
+	config.LatencyBudget = 0
+	p, err = config.NewPlaces(syntheticProvider{})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+	if r, partial := p.GetCompletionsPartial(context.Background(), places.Query{Text: "Oanienburger"}); len(r) == 0 || partial {
+		t.Errorf("got %d (partial: %t), want > 0", len(r), partial)
+	}
+}
@@ -0,0 +1,69 @@
+package places
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// decayEpoch is the reference time of decayed relevance scores. As scores are
+// relative to a fixed point in time, they remain comparable (and may be
+// persisted) without ever being updated for the passing of time.
+var decayEpoch = time.Unix(0, 0)
+
+// decayExponent returns the exponent (i.e. the number of half-lives passed
+// since decayEpoch) of a hit at the given time.
+func decayExponent(t time.Time, halfLife time.Duration) float64 {
+	return float64(t.Sub(decayEpoch)) / float64(halfLife)
+}
+
+// addScore adds a hit at the given time to the decayed relevance score of the
+// place. The score is the sum of 2^decayExponent(t) over all hits at times t
+// (i.e. each hit counts as 1 at its time and halves with each half-life). In
+// order to not overflow, the score is kept as its logarithm (base 2). Updates
+// are lock free (compare and swap).
+func (p *Place) addScore(t time.Time, halfLife time.Duration) {
+	x := decayExponent(t, halfLife)
+	for {
+		oldBits := atomic.LoadUint64(&p.score)
+		score := x
+		if oldBits != 0 {
+
+			// log2(2^l + 2^x) computed without leaving the log domain
+			l := math.Float64frombits(oldBits)
+			score = math.Max(l, x) + math.Log2(1+math.Exp2(-math.Abs(l-x)))
+		}
+		if atomic.CompareAndSwapUint64(&p.score, oldBits, scoreBits(score)) {
+			return
+		}
+	}
+}
+
+// logScore returns the logarithm (base 2) of the decayed relevance score of the
+// place (or -Inf if there are no hits).
+func (p *Place) logScore() float64 {
+	bits := atomic.LoadUint64(&p.score)
+	if bits == 0 {
+		return math.Inf(-1)
+	}
+	return math.Float64frombits(bits)
+}
+
+// scoreBits returns the bits to store for the given (log) score. As 0 bits mean
+// "no hits", a score of 0 is stored as the smallest positive float instead.
+func scoreBits(score float64) uint64 {
+	if score == 0 {
+		score = math.SmallestNonzeroFloat64
+	}
+	return math.Float64bits(score)
+}
+
+// relevanceOf returns the value to rank the given place by. That is, its
+// decayed relevance score if a RelevanceHalfLife is configured and the number
+// of hits otherwise.
+func (bp *Places) relevanceOf(p *Place) float64 {
+	if bp.config.RelevanceHalfLife > 0 {
+		return p.logScore()
+	}
+	return float64(atomic.LoadUint64(&p.Relevance))
+}
@@ -0,0 +1,128 @@
+package places
+
+import (
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// districtSeparatorRegexp matches the separators of names of merged districts
+// (e.g. "Friedrichshain-Kreuzberg" or "Charlottenburg / Wilmersdorf").
+var districtSeparatorRegexp = regexp.MustCompile(`\s*(-|/)\s*`)
+
+// districtNames splits the name of a (merged) district into the names of its parts.
+func districtNames(district string) []string {
+	var names []string
+	for _, name := range districtSeparatorRegexp.Split(district, -1) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// computeDistrictPlaces computes places (of class DistrictClass) for the given
+// districts. As districts may be merged (e.g. "Friedrichshain-Kreuzberg"), there
+// is a place for each (distinct) part of the names (e.g. "Friedrichshain" and
+// "Kreuzberg"). The position of district places is the centroid of the (street-,
+// location- and house number) places in all districts containing the part. The
+// district of a district place is the one with the most places among those. As
+// IDs must be stable (e.g. for relevance snapshots), district places are identified
+// by DistrictClass<<32 plus a hash of their (simple) name.
+func computeDistrictPlaces(districtsMap DistrictMap, placesMap PlaceMap) []*Place {
+
+	// count and sum up coordinates of member places by district
+	type centroid struct {
+		lat, lon float64
+		count    int
+	}
+	centroids := make(map[string]*centroid)
+	for _, d := range districtsMap {
+		centroids[d.District] = &centroid{}
+	}
+	for _, p := range placesMap {
+		if p.District == nil || p.Class == DistrictClass {
+			continue
+		}
+		if c, ok := centroids[p.District.District]; ok {
+			c.lat += p.Lat
+			c.lon += p.Lon
+			c.count += 1
+		}
+	}
+
+	// sort districts (for the sake of stable names and IDs)
+	districts := make([]string, 0, len(centroids))
+	for district := range centroids {
+		districts = append(districts, district)
+	}
+	sort.Strings(districts)
+
+	// merge the centroids of districts by (simple) name of their parts
+	type part struct {
+		name     string
+		district string
+		centroid
+	}
+	var simpleNames []string
+	parts := make(map[string]*part)
+	for _, district := range districts {
+		c := centroids[district]
+		if c.count == 0 {
+			continue
+		}
+		for _, name := range districtNames(district) {
+			simpleName := SanitizeString(name)
+			pt, ok := parts[simpleName]
+			if !ok {
+				pt = &part{name: name, district: district}
+				parts[simpleName] = pt
+				simpleNames = append(simpleNames, simpleName)
+			}
+			if c.count > centroids[pt.district].count {
+				pt.district = district
+			}
+			pt.lat += c.lat
+			pt.lon += c.lon
+			pt.count += c.count
+		}
+	}
+	sort.Strings(simpleNames)
+
+	// a place for each part
+	districtPlaces := make([]*Place, 0, len(simpleNames))
+	ids := make(map[int64]struct{}, len(simpleNames))
+	for _, simpleName := range simpleNames {
+		pt := parts[simpleName]
+		districtPlaces = append(districtPlaces, &Place{
+			ID:         districtID(simpleName, ids),
+			Class:      DistrictClass,
+			Name:       pt.name,
+			District:   &District{District: pt.district},
+			Lat:        pt.lat / float64(pt.count),
+			Lon:        pt.lon / float64(pt.count),
+			SimpleName: simpleName,
+			Phonetic:   ColognePhonetic(pt.name),
+		})
+	}
+	return districtPlaces
+}
+
+// districtID returns the ID of the district place with the given simple name.
+// That is, DistrictClass<<32 plus the (32-bit) FNV-1a hash of the name (or, in
+// case of collisions with the given IDs, the next free one). The ID is added to
+// the given IDs.
+func districtID(simpleName string, ids map[int64]struct{}) int64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(simpleName))
+	n := h.Sum32()
+	for {
+		id := int64(DistrictClass)<<32 + int64(n)
+		if _, exists := ids[id]; !exists && n != 0 {
+			ids[id] = struct{}{}
+			return id
+		}
+		n += 1
+	}
+}
@@ -0,0 +1,549 @@
+package places
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// computeFuzzyIndex computes a SymSpell-style deletion dictionary over the (max)
+// prefixes of street- and location names. That is, each variant of a prefix
+// derived by deleting up to maxDistance runes is mapped to the prefix. Two
+// prefixes within an edit distance of maxDistance share at least one variant.
+func computeFuzzyIndex(streetsAndLocations []*Place, maxPrefixLength, maxDistance int) map[string][]string {
+	index := make(map[string][]string)
+	if maxDistance < 1 {
+		return index
+	}
+
+	// collect (max) prefixes
+	prefixes := make(map[string]struct{})
+	for _, p := range streetsAndLocations {
+		runes := []rune(p.SimpleName)
+		prefixes[string(runes[:Min(len(runes), maxPrefixLength)])] = struct{}{}
+	}
+
+	// map deletion variants to prefixes
+	for prefix := range prefixes {
+		for variant := range deletionVariants(prefix, maxDistance) {
+			index[variant] = append(index[variant], prefix)
+		}
+	}
+
+	return index
+}
+
+// deletionVariants returns all variants of s derived by deleting up to maxDistance runes (including s itself).
+func deletionVariants(s string, maxDistance int) map[string]struct{} {
+	variants := map[string]struct{}{s: {}}
+	current := []string{s}
+	for d := 0; d < maxDistance; d++ {
+		var next []string
+		for _, v := range current {
+			runes := []rune(v)
+			for i := range runes {
+				variant := string(runes[:i]) + string(runes[i+1:])
+				if _, ok := variants[variant]; !ok {
+					variants[variant] = struct{}{}
+					next = append(next, variant)
+				}
+			}
+		}
+		current = next
+	}
+	return variants
+}
+
+// fuzzyTrie holds the simple names of street- and location places in lexical
+// order along with the length of the common prefix of each name with its
+// predecessor. Thus, names can be walked as a trie, i.e. the distance matrix of
+// a common prefix is computed once and names below a prefix (i.e. a node)
+// exceeding the distance bound are skipped at once.
+type fuzzyTrie struct {
+
+	// the simple names (as runes) in lexical order
+	names [][]rune
+
+	// the length of the common prefix of each name and its predecessor
+	lcps []int
+
+	// the index of the first node of each name (i.e. the nodes of name i are the
+	// prefixes of length lcps[i]+1 to len(names[i]))
+	nodes []int
+
+	// the minimum and maximum length of the names below each node
+	minLengths []int
+	maxLengths []int
+
+	// the position of each name's place in streetsAndLocations
+	positions []int
+
+	// street- and location places (in the order of Places.streetsAndLocations)
+	streetsAndLocations []*Place
+}
+
+// computeFuzzyTrie computes the fuzzy trie over the given places in lexical order.
+func computeFuzzyTrie(lexicalOrder, streetsAndLocations []*Place) *fuzzyTrie {
+	positions := make(map[*Place]int, len(streetsAndLocations))
+	for i, p := range streetsAndLocations {
+		positions[p] = i
+	}
+	n := len(lexicalOrder)
+	trie := &fuzzyTrie{
+		names:               make([][]rune, n),
+		lcps:                make([]int, n),
+		nodes:               make([]int, n),
+		positions:           make([]int, n),
+		streetsAndLocations: streetsAndLocations,
+	}
+
+	// compute names, common prefixes and nodes
+	nodeCount := 0
+	maxLength := 0
+	for i, p := range lexicalOrder {
+		trie.names[i] = []rune(p.SimpleName)
+		trie.positions[i] = positions[p]
+		if i > 0 {
+			prev := trie.names[i-1]
+			for trie.lcps[i] < Min(len(prev), len(trie.names[i])) && prev[trie.lcps[i]] == trie.names[i][trie.lcps[i]] {
+				trie.lcps[i] += 1
+			}
+		}
+		trie.nodes[i] = nodeCount
+		nodeCount += len(trie.names[i]) - trie.lcps[i]
+		maxLength = Max(maxLength, len(trie.names[i]))
+	}
+
+	// compute the lengths below nodes (backwards, as the names below a node follow the node's name)
+	trie.minLengths = make([]int, nodeCount)
+	trie.maxLengths = make([]int, nodeCount)
+	minLengths := make([]int, maxLength+1)
+	maxLengths := make([]int, maxLength+1)
+	for i := n - 1; i >= 0; i-- {
+		length := len(trie.names[i])
+		for t := 1; t <= length; t++ {
+			if i+1 < n && trie.lcps[i+1] >= t {
+				minLengths[t] = Min(minLengths[t], length)
+				maxLengths[t] = Max(maxLengths[t], length)
+			} else {
+				minLengths[t] = length
+				maxLengths[t] = length
+			}
+		}
+		for t := trie.lcps[i] + 1; t <= length; t++ {
+			trie.minLengths[trie.node(i, t)] = minLengths[t]
+			trie.maxLengths[trie.node(i, t)] = maxLengths[t]
+		}
+	}
+
+	return trie
+}
+
+// node returns the index of the node of name i at depth t (see fuzzyTrie.nodes).
+func (trie *fuzzyTrie) node(i, t int) int {
+	return trie.nodes[i] + t - trie.lcps[i] - 1
+}
+
+// fuzzyCandidates returns the places to compute (unrefined) results for in
+// case of an unknown (max) prefix of the input (along with their distances, if
+// known). That is, the places which might be ranked among the first
+// MinCompletionCount results (see fuzzySearch). Thus, results are identical to
+// those of a full scan. If the fuzzy index is disabled (or can't bound results,
+// e.g. in case of tokens or custom rankings), fuzzyCandidates returns all
+// streets and locations.
+func (bp *Places) fuzzyCandidates(simpleInput string, tokens []string) ([]*Place, map[*Place]int) {
+	if bp.config.FuzzyDistance < 1 || bp.config.MinCompletionCount < 1 || !bp.boundedRanking(tokens) {
+		return bp.streetsAndLocations, nil
+	}
+
+	search := &fuzzySearch{
+		trie:    bp.fuzzyTrie,
+		input:   []rune(simpleInput),
+		count:   bp.config.MinCompletionCount,
+		cut:     bp.config.DistanceCut,
+		damerau: bp.config.DistanceMetric == DamerauMetric,
+		bound:   math.MaxInt32,
+	}
+
+	// walk the names of similar prefixes first (closest first, as these likely
+	// bound the search early), then all others
+	seedRanges := bp.seedRanges(simpleInput)
+	for _, r := range seedRanges {
+		search.walk(r[0], r[1])
+	}
+	sort.Slice(seedRanges, func(i, j int) bool {
+		return seedRanges[i][0] < seedRanges[j][0]
+	})
+	lo := 0
+	for _, r := range seedRanges {
+		search.walk(lo, r[0])
+		lo = r[1]
+	}
+	search.walk(lo, len(bp.fuzzyTrie.names))
+
+	// keep the places within the final bound (in the order of streetsAndLocations)
+	var positions []int
+	distances := make(map[*Place]int)
+	for k, position := range search.positions {
+		if search.distances[k] <= search.bound {
+			positions = append(positions, position)
+			distances[bp.streetsAndLocations[position]] = search.distances[k]
+		}
+	}
+	sort.Ints(positions)
+	places := make([]*Place, len(positions))
+	for i, position := range positions {
+		places[i] = bp.streetsAndLocations[position]
+	}
+	return places, distances
+}
+
+// seedRanges returns the (disjoint) ranges in lexical order of the places whose
+// (max) prefix is within FuzzyDistance edits of the (max) prefix of the input,
+// ordered by the distance of their prefix.
+func (bp *Places) seedRanges(simpleInput string) [][2]int {
+	runes := []rune(simpleInput)
+	if len(runes) < bp.config.MaxPrefixLength {
+		return nil
+	}
+	inputPrefix := string(runes[:bp.config.MaxPrefixLength])
+
+	// collect prefixes sharing a deletion variant with the input prefix
+	prefixes := make(map[string]struct{})
+	for variant := range deletionVariants(inputPrefix, bp.config.FuzzyDistance) {
+		for _, prefix := range bp.fuzzyIndex[variant] {
+			prefixes[prefix] = struct{}{}
+		}
+	}
+
+	// collect the ranges of prefixes within FuzzyDistance
+	var ranges [][2]int
+	var distances []int
+	for prefix := range prefixes {
+		if d := bp.distance(inputPrefix, prefix); d <= bp.config.FuzzyDistance {
+			lo, hi := bp.prefixRange(prefix)
+			ranges = append(ranges, [2]int{lo, hi})
+			distances = append(distances, d)
+		}
+	}
+	sort.Sort(byPrefixDistance{ranges: ranges, distances: distances})
+	return ranges
+}
+
+// byPrefixDistance sorts ranges by the distance of their prefix (and position).
+type byPrefixDistance struct {
+	ranges    [][2]int
+	distances []int
+}
+
+func (sr byPrefixDistance) Len() int {
+	return len(sr.ranges)
+}
+
+func (sr byPrefixDistance) Less(i, j int) bool {
+	if sr.distances[i] != sr.distances[j] {
+		return sr.distances[i] < sr.distances[j]
+	}
+	return sr.ranges[i][0] < sr.ranges[j][0]
+}
+
+func (sr byPrefixDistance) Swap(i, j int) {
+	sr.ranges[i], sr.ranges[j] = sr.ranges[j], sr.ranges[i]
+	sr.distances[i], sr.distances[j] = sr.distances[j], sr.distances[i]
+}
+
+// boundedRanking returns true, if the ranking of results is bound by their
+// distances. That is, results of the default ranking (without tokens, as token
+// coverage ranks first) with a distance exceeding the distance of another result
+// by more than DistanceCut are ranked lower. In addition, the distance metric must
+// be an edit distance (as Levenshtein and Damerau-Levenshtein).
+func (bp *Places) boundedRanking(tokens []string) bool {
+	if len(tokens) > 0 {
+		return false
+	}
+	if _, ok := bp.ranker.(defaultRanker); !ok {
+		return false
+	}
+	if bp.config.DistanceMetric != LevenshteinMetric && bp.config.DistanceMetric != DamerauMetric {
+		return false
+	}
+	return true
+}
+
+// fuzzySearch is a search for the places within d + cut edits of the input,
+// with d the count-th smallest distance of all places (Levenshtein or, if
+// damerau is true, Damerau-Levenshtein). Places beyond are ranked below (at
+// least) count places and can thus be skipped.
+type fuzzySearch struct {
+	trie    *fuzzyTrie
+	input   []rune
+	count   int
+	cut     int
+	damerau bool
+
+	// the count smallest distances so far (sorted) and the resulting bound
+	smallest []int
+	bound    int
+
+	// the columns of the distance matrix (one per rune of the name plus the initial one)
+	columns columns
+
+	// the positions and distances of the places within the bound (so far)
+	positions []int
+	distances []int
+}
+
+// columns computes the columns of the distance matrix of an input (rows) and a
+// name (columns), one column per rune of the name.
+type columns interface {
+
+	// next computes the column of depth t (i.e. for the t-th rune of the name)
+	// and returns the lower bound of the distances of names (with lengths from
+	// minLength to maxLength) below the column. Lower bounds not exceeding the
+	// given bound might be returned as is (i.e. without computing the minimum).
+	next(name []rune, t, minLength, maxLength, bound int) int
+
+	// distance returns the distance of the input and the name of length t.
+	distance(t int) int
+}
+
+// add adds the given distance (of a place within the bound) and tightens the bound.
+func (search *fuzzySearch) add(d int) {
+	if len(search.smallest) == search.count && d >= search.smallest[search.count-1] {
+		return
+	}
+	i := sort.SearchInts(search.smallest, d)
+	search.smallest = append(search.smallest, 0)
+	copy(search.smallest[i+1:], search.smallest[i:])
+	search.smallest[i] = d
+	if len(search.smallest) > search.count {
+		search.smallest = search.smallest[:search.count]
+	}
+	if len(search.smallest) == search.count {
+		search.bound = search.smallest[search.count-1] + search.cut
+	}
+}
+
+// walk walks the names from lo to hi. While
+// walking names, the distance matrix is computed column by column (i.e. rune
+// by rune of the name). Names below a node whose column exceeds the bound are
+// skipped.
+func (search *fuzzySearch) walk(lo, hi int) {
+	trie := search.trie
+	if search.columns == nil {
+		if search.damerau || len(search.input) > 64 {
+			search.columns = newMatrixColumns(search.input, search.damerau)
+		} else {
+			search.columns = newBitColumns(search.input)
+		}
+	}
+
+	valid := 0 // the number of valid columns (beyond the initial one)
+	for i := lo; i < hi; {
+
+		// columns are valid up to the common prefix with the last name walked
+		valid = Min(valid, trie.lcps[i])
+
+		name := trie.names[i]
+		pruned := false
+		for t := valid + 1; t <= len(name); t++ {
+
+			// the nodes of depths up to lcps[i] are those of preceding names (thus unknown here)
+			minLength, maxLength := t, math.MaxInt32
+			if t > trie.lcps[i] {
+				node := trie.node(i, t)
+				minLength, maxLength = trie.minLengths[node], trie.maxLengths[node]
+			}
+			lowerBound := search.columns.next(name, t, minLength, maxLength, search.bound)
+			valid = t
+
+			// skip all names below this node if the bound is exceeded
+			if lowerBound > search.bound {
+				for i += 1; i < hi && trie.lcps[i] >= t; i++ {
+				}
+				pruned = true
+				break
+			}
+		}
+		if pruned {
+			continue
+		}
+		if d := search.columns.distance(len(name)); d <= search.bound {
+			search.positions = append(search.positions, trie.positions[i])
+			search.distances = append(search.distances, d)
+			search.add(d)
+		}
+		i += 1
+	}
+}
+
+// lengthGap returns the minimum difference of the given remaining length of
+// the input and the remaining lengths (from minRemaining to maxRemaining) of names.
+func lengthGap(remaining, minRemaining, maxRemaining int) int {
+	if remaining < minRemaining {
+		return minRemaining - remaining
+	}
+	if remaining > maxRemaining {
+		return remaining - maxRemaining
+	}
+	return 0
+}
+
+// matrixColumns computes columns of the distance matrix cell by cell (as
+// needed for transpositions or long inputs).
+type matrixColumns struct {
+	input   []rune
+	damerau bool
+	cells   [][]int
+}
+
+// newMatrixColumns returns the matrix columns for the given input.
+func newMatrixColumns(input []rune, damerau bool) *matrixColumns {
+	initial := make([]int, len(input)+1)
+	for j := range initial {
+		initial[j] = j
+	}
+	return &matrixColumns{input: input, damerau: damerau, cells: [][]int{initial}}
+}
+
+// next implements the columns interface for matrixColumns. As any path through
+// the matrix crosses the column (or, in case of transpositions, the column
+// before), the distance of a name below is at least the minimum over these
+// columns of a cell plus the difference in the remaining lengths.
+func (mc *matrixColumns) next(name []rune, t, minLength, maxLength, _ int) int {
+	input := mc.input
+	n := len(input)
+	if t == len(mc.cells) {
+		mc.cells = append(mc.cells, make([]int, n+1))
+	}
+	prev, column := mc.cells[t-1], mc.cells[t]
+	column[0] = t
+	lowerBound := t + lengthGap(n, minLength-t, maxLength-t)
+	for j := 1; j <= n; j++ {
+		cost := 1
+		if name[t-1] == input[j-1] {
+			cost = 0
+		}
+		d := Min(Min(prev[j]+1, column[j-1]+1), prev[j-1]+cost)
+		if mc.damerau && t > 1 && j > 1 && name[t-1] == input[j-2] && name[t-2] == input[j-1] {
+			d = Min(d, mc.cells[t-2][j-2]+1)
+		}
+		column[j] = d
+		lowerBound = Min(lowerBound, d+lengthGap(n-j, minLength-t, maxLength-t))
+	}
+	if mc.damerau {
+		for j, d := range prev {
+			lowerBound = Min(lowerBound, d+lengthGap(n-j, minLength-t+1, maxLength-t+1))
+		}
+	}
+	return lowerBound
+}
+
+// distance implements the columns interface for matrixColumns.
+func (mc *matrixColumns) distance(t int) int {
+	return mc.cells[t][len(mc.input)]
+}
+
+// bitColumns computes columns of the (Levenshtein) distance matrix bit-parallel
+// (see Myers, "A fast bit-vector algorithm for approximate string matching based
+// on dynamic programming", 1999). That is, a column is represented by bit vectors
+// of its positive and negative vertical deltas (i.e. inputs of up to 64 runes).
+type bitColumns struct {
+	n int
+
+	// the positions of each rune in the input as bit vector
+	ascii [128]uint64
+	other map[rune]uint64
+
+	// the positive and negative vertical deltas and the last cell of each column
+	pv     []uint64
+	mv     []uint64
+	scores []int
+}
+
+// newBitColumns returns the bit columns for the given input.
+func newBitColumns(input []rune) *bitColumns {
+	bc := &bitColumns{n: len(input), other: make(map[rune]uint64)}
+	for j, r := range input {
+		if r < 128 {
+			bc.ascii[r] |= 1 << uint(j)
+		} else {
+			bc.other[r] |= 1 << uint(j)
+		}
+	}
+	bc.pv = []uint64{^uint64(0)}
+	bc.mv = []uint64{0}
+	bc.scores = []int{len(input)}
+	return bc
+}
+
+// next implements the columns interface for bitColumns. As any path through the
+// matrix crosses the column, the distance of a name below is at least the minimum
+// of a cell plus the difference in the remaining lengths.
+func (bc *bitColumns) next(name []rune, t, minLength, maxLength, bound int) int {
+	if t == len(bc.pv) {
+		bc.pv = append(bc.pv, 0)
+		bc.mv = append(bc.mv, 0)
+		bc.scores = append(bc.scores, 0)
+	}
+
+	// compute the column from the previous one
+	var eq uint64
+	if r := name[t-1]; r < 128 {
+		eq = bc.ascii[r]
+	} else {
+		eq = bc.other[r]
+	}
+	pv, mv := bc.pv[t-1], bc.mv[t-1]
+	xv := eq | mv
+	xh := (((eq & pv) + pv) ^ pv) | eq
+	ph := mv | ^(xh | pv)
+	mh := pv & xh
+	score := bc.scores[t-1]
+	last := uint64(1) << uint(bc.n-1)
+	if bc.n == 0 {
+		score += 1
+	} else if ph&last != 0 {
+		score += 1
+	} else if mh&last != 0 {
+		score -= 1
+	}
+	ph = ph<<1 | 1
+	mh = mh << 1
+	bc.pv[t] = mh | ^(xv | ph)
+	bc.mv[t] = ph & xv
+	bc.scores[t] = score
+
+	// As cells (top down) change by at most 1, cells plus the difference in
+	// remaining lengths don't decrease beyond the range of cells whose
+	// remaining lengths match (i.e. it suffices to check cells in that range).
+	from, to := Max(0, bc.n-(maxLength-t)), bc.n-(minLength-t)
+	if to < 0 {
+		return -to
+	}
+	to = Min(to, bc.n)
+
+	// check the ends first (as these likely don't exceed the bound anyway)
+	pv, mv = bc.pv[t], bc.mv[t]
+	d := bc.cell(t, from)
+	lowerBound := Min(d, bc.cell(t, to))
+	if lowerBound <= bound {
+		return lowerBound
+	}
+	for j := from + 1; j < to; j++ {
+		d += int(pv>>uint(j-1)&1) - int(mv>>uint(j-1)&1)
+		lowerBound = Min(lowerBound, d)
+	}
+	return lowerBound
+}
+
+// cell returns the j-th cell of the column of depth t.
+func (bc *bitColumns) cell(t, j int) int {
+	mask := uint64(1)<<uint(j) - 1
+	return t + bits.OnesCount64(bc.pv[t]&mask) - bits.OnesCount64(bc.mv[t]&mask)
+}
+
+// distance implements the columns interface for bitColumns.
+func (bc *bitColumns) distance(t int) int {
+	return bc.scores[t]
+}
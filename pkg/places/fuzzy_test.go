@@ -0,0 +1,121 @@
+package places_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/heimdalr/berlinplaces/pkg/places"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// earlyTypos are inputs with typos inside the (max) prefix.
+var earlyTypos = []string{
+	"oanienburgerstraße",
+	"oanienbu",
+	"tiregartenufer",
+	"köpnickerstr",
+	"shcönhauserallee",
+	"wamschauerstr",
+}
+
+// typos returns count inputs derived from the names of the given places by
+// (pseudo random) deletions, insertions, substitutions and transpositions. As
+// exact matches increase relevance, inputs matching a name are skipped.
+func typos(placeMap places.PlaceMap, count int) []string {
+	var names []string
+	exact := make(map[string]bool)
+	for _, p := range placeMap {
+		names = append(names, p.SimpleName)
+		exact[p.SimpleName] = true
+	}
+	sort.Strings(names)
+	random := rand.New(rand.NewSource(1))
+	var inputs []string
+	for len(inputs) < count {
+		runes := []rune(names[random.Intn(len(names))])
+		for edits := 1 + random.Intn(3); edits > 0 && len(runes) > 2; edits-- {
+			i := random.Intn(len(runes) - 1)
+			switch random.Intn(4) {
+			case 0:
+				runes = append(runes[:i], runes[i+1:]...)
+			case 1:
+				runes = append(runes[:i], append([]rune{'x'}, runes[i:]...)...)
+			case 2:
+				runes[i] = 'e'
+			default:
+				runes[i], runes[i+1] = runes[i+1], runes[i]
+			}
+		}
+		if input := string(runes[:places.Min(len(runes), 4+random.Intn(16))]); !exact[input] {
+			inputs = append(inputs, input)
+		}
+	}
+	return inputs
+}
+
+func TestPlaces_FuzzyIndex(t *testing.T) {
+
+	_, placeMap, _, _ := syntheticProvider{}.Get()
+	inputs := append(earlyTypos, "baum", "oranienburgerstraß", "kaiserdammstr", "bism", "goethesteg")
+	inputs = append(inputs, typos(placeMap, 50)...)
+
+	for _, metric := range []places.DistanceMetric{places.LevenshteinMetric, places.DamerauMetric} {
+
+		// the fuzzy index
+		config := *places.DefaultConfig
+		config.DistanceMetric = metric
+		config.CacheTTL = -1 // don't cache results
+		fuzzyPlaces, err := config.NewPlaces(syntheticProvider{})
+		if err != nil {
+			t.Fatal(fmt.Errorf("failed to init places: %w", err))
+		}
+
+		// full scans
+		config.FuzzyDistance = 0
+		scanPlaces, err := config.NewPlaces(syntheticProvider{})
+		if err != nil {
+			t.Fatal(fmt.Errorf("failed to init places: %w", err))
+		}
+
+		for _, text := range inputs {
+			t.Run(fmt.Sprintf("%s %s", metric, text), func(t *testing.T) {
+				got := fuzzyPlaces.GetCompletions(context.Background(), places.Query{Text: text})
+				want := scanPlaces.GetCompletions(context.Background(), places.Query{Text: text})
+				if len(got) != len(want) {
+					t.Fatalf("got %d, want %d", len(got), len(want))
+				}
+
+				// results must be identical to those of the full scan
+				for i := range got {
+					if got[i].Place.ID != want[i].Place.ID || got[i].Distance != want[i].Distance || got[i].Match != want[i].Match {
+						t.Errorf("%d: got %s (%d), want %s (%d)", i,
+							got[i].Place.Name, got[i].Distance, want[i].Place.Name, want[i].Distance)
+					}
+				}
+			})
+		}
+	}
+}
+
+func benchmarkEarlyTypos(b *testing.B, fuzzyDistance int) {
+	config := *places.DefaultConfig
+	config.FuzzyDistance = fuzzyDistance
+	config.CacheTTL = -1 // don't cache results
+	p, err := config.NewPlaces(syntheticProvider{})
+	if err != nil {
+		b.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.GetCompletions(context.Background(), places.Query{Text: earlyTypos[i%len(earlyTypos)]})
+	}
+}
+
+func BenchmarkPlaces_EarlyTyposFuzzyIndex(b *testing.B) {
+	benchmarkEarlyTypos(b, 2)
+}
+
+func BenchmarkPlaces_EarlyTyposFullScan(b *testing.B) {
+	benchmarkEarlyTypos(b, 0)
+}
@@ -0,0 +1,201 @@
+package places
+
+import (
+	"container/heap"
+	"math"
+)
+
+// earthRadius is the mean earth radius (in meters).
+const earthRadius = 6371008.8
+
+// Haversine returns the great-circle distance (in meters) between two points given in degrees.
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// kdPoint is a place projected onto a plane (coordinates in meters).
+type kdPoint struct {
+	x, y  float64
+	place *Place
+}
+
+// kdTree is a 2-dimensional k-d tree over place coordinates. The tree is stored
+// implicitly in a slice: the median of each (sub-) slice is the node, the lower
+// half is the left and the upper half the right subtree. Levels alternate
+// between splitting along x and y.
+type kdTree struct {
+	points []kdPoint
+
+	// cosine of the mean latitude (used for the equirectangular projection)
+	cosLat float64
+}
+
+// newKDTree builds a k-d tree over the given places (ignoring places without coordinates).
+func newKDTree(places []*Place) *kdTree {
+
+	// compute the mean latitude as the reference for the projection
+	var latSum float64
+	var count int
+	for _, p := range places {
+		if p.Lat != 0 || p.Lon != 0 {
+			latSum += p.Lat
+			count += 1
+		}
+	}
+	t := &kdTree{cosLat: 1}
+	if count == 0 {
+		return t
+	}
+	t.cosLat = math.Cos(latSum / float64(count) * math.Pi / 180)
+
+	// project places
+	t.points = make([]kdPoint, 0, count)
+	for _, p := range places {
+		if p.Lat != 0 || p.Lon != 0 {
+			x, y := t.project(p.Lat, p.Lon)
+			t.points = append(t.points, kdPoint{x: x, y: y, place: p})
+		}
+	}
+
+	t.build(0, len(t.points), 0)
+
+	return t
+}
+
+// project maps the given coordinates onto the plane (equirectangular projection).
+func (t *kdTree) project(lat, lon float64) (float64, float64) {
+	return lon * math.Pi / 180 * earthRadius * t.cosLat, lat * math.Pi / 180 * earthRadius
+}
+
+// build arranges points[lo:hi] such that the median (wrt. the axis of the given
+// depth) is in the middle and recurses into both halves.
+func (t *kdTree) build(lo, hi, depth int) {
+	if hi-lo < 2 {
+		return
+	}
+	mid := (lo + hi) / 2
+	t.selectNth(lo, hi, mid, depth%2)
+	t.build(lo, mid, depth+1)
+	t.build(mid+1, hi, depth+1)
+}
+
+// axisValue returns the coordinate of the i-th point along the given axis.
+func (t *kdTree) axisValue(i, axis int) float64 {
+	if axis == 0 {
+		return t.points[i].x
+	}
+	return t.points[i].y
+}
+
+// selectNth partially sorts points[lo:hi] (quickselect) such that the element at
+// n is the one that would be there if the slice was sorted along the given axis.
+func (t *kdTree) selectNth(lo, hi, n, axis int) {
+	hi -= 1
+	for lo < hi {
+
+		// partition around the middle element
+		pivot := t.axisValue((lo+hi)/2, axis)
+		i, j := lo, hi
+		for i <= j {
+			for t.axisValue(i, axis) < pivot {
+				i += 1
+			}
+			for t.axisValue(j, axis) > pivot {
+				j -= 1
+			}
+			if i <= j {
+				t.points[i], t.points[j] = t.points[j], t.points[i]
+				i += 1
+				j -= 1
+			}
+		}
+
+		// continue in the part containing n
+		if n <= j {
+			hi = j
+		} else if n >= i {
+			lo = i
+		} else {
+			return
+		}
+	}
+}
+
+// nearest returns (up to) the k points closest to the given coordinates (closest first).
+func (t *kdTree) nearest(lat, lon float64, k int) []*kdPoint {
+	if k < 1 || len(t.points) == 0 {
+		return nil
+	}
+	x, y := t.project(lat, lon)
+	h := make(kdHeap, 0, k)
+	t.search(0, len(t.points), 0, x, y, k, &h)
+
+	// the heap pops the farthest point first, thus fill the result from the back
+	points := make([]*kdPoint, h.Len())
+	for i := len(points) - 1; i >= 0; i-- {
+		points[i] = heap.Pop(&h).(kdCandidate).point
+	}
+	return points
+}
+
+// search recursively collects the k nearest points of points[lo:hi] into h.
+func (t *kdTree) search(lo, hi, depth int, x, y float64, k int, h *kdHeap) {
+	if lo >= hi {
+		return
+	}
+	mid := (lo + hi) / 2
+	p := &t.points[mid]
+
+	// consider the node itself
+	dx, dy := p.x-x, p.y-y
+	dist := dx*dx + dy*dy
+	if h.Len() < k {
+		heap.Push(h, kdCandidate{point: p, dist: dist})
+	} else if dist < (*h)[0].dist {
+		(*h)[0] = kdCandidate{point: p, dist: dist}
+		heap.Fix(h, 0)
+	}
+
+	// descend into the side containing the target first
+	diff := dx
+	if depth%2 == 1 {
+		diff = dy
+	}
+	if diff > 0 {
+		t.search(lo, mid, depth+1, x, y, k, h)
+		if h.Len() < k || diff*diff < (*h)[0].dist {
+			t.search(mid+1, hi, depth+1, x, y, k, h)
+		}
+	} else {
+		t.search(mid+1, hi, depth+1, x, y, k, h)
+		if h.Len() < k || diff*diff < (*h)[0].dist {
+			t.search(lo, mid, depth+1, x, y, k, h)
+		}
+	}
+}
+
+// kdCandidate is a point and its (squared) distance to the search target.
+type kdCandidate struct {
+	point *kdPoint
+	dist  float64
+}
+
+// kdHeap is a max-heap of candidates (i.e. the farthest candidate is on top).
+type kdHeap []kdCandidate
+
+func (h kdHeap) Len() int            { return len(h) }
+func (h kdHeap) Less(i, j int) bool  { return h[i].dist > h[j].dist }
+func (h kdHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *kdHeap) Push(x interface{}) { *h = append(*h, x.(kdCandidate)) }
+func (h *kdHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	c := old[n-1]
+	*h = old[:n-1]
+	return c
+}
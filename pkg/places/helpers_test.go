@@ -0,0 +1,75 @@
+package places_test
+
+import "github.com/heimdalr/berlinplaces/pkg/places"
+
+// syntheticProvider provides streets with synthetic (but Berlin-like) names.
+type syntheticProvider struct{}
+
+// Get implements the Provider interface for syntheticProvider.
+func (syntheticProvider) Get() (places.DistrictMap, places.PlaceMap, *places.Metrics, error) {
+	heads := []string{
+		"Oranien", "Tiergarten", "Köpenicker", "Friedrich", "Karl", "Rosa", "Linden", "Kastanien",
+		"Schönhauser", "Prenzlauer", "Greifswalder", "Danziger", "Warschauer", "Frankfurter", "Landsberger",
+		"Mühlen", "Spree", "Havel", "Wilmersdorfer", "Kaiser", "König", "Bismarck", "Goethe", "Schiller",
+		"Lessing", "Heine", "Brecht", "Fontane", "Humboldt", "Virchow", "Koch", "Planck", "Einstein",
+		"Hermann", "Sonnen", "Wald", "Birken", "Eichen", "Buchen", "Ahorn",
+	}
+	middles := []string{
+		"", "burger", "berger", "felder", "dorfer", "hagener", "stadter", "hofer", "walder", "seer",
+		"brücken", "tor", "markt", "garten", "park", "heide", "wiesen", "auer", "thaler", "heimer",
+		"städter", "ufer", "kamp", "winkel", "grund",
+	}
+	tails := []string{
+		"straße", "weg", "allee", "platz", "ufer", "damm", "ring", "gasse", "chaussee", "steig", "pfad", "zeile",
+	}
+
+	district := &places.District{Postcode: "10961", District: "Friedrichshain-Kreuzberg"}
+	districtMap := places.DistrictMap{district.Postcode: district}
+	placeMap := make(places.PlaceMap)
+	var id int64
+	for _, h := range heads {
+		for _, m := range middles {
+			for _, t := range tails {
+				id += 1
+				name := h + m + t
+				placeMap[id] = &places.Place{
+					ID:         id,
+					Class:      places.StreetClass,
+					Name:       name,
+					District:   district,
+					Length:     int(id),
+					Lat:        52.5 + float64(id%100)/1000,
+					Lon:        13.4 + float64(id/100)/1000,
+					SimpleName: places.SanitizeString(name),
+					Phonetic:   places.ColognePhonetic(name),
+				}
+			}
+		}
+	}
+
+	return districtMap, placeMap, &places.Metrics{StreetCount: int32(len(placeMap))}, nil
+}
+
+// namesProvider provides streets with the given names.
+type namesProvider []string
+
+// Get implements the Provider interface for namesProvider.
+func (names namesProvider) Get() (places.DistrictMap, places.PlaceMap, *places.Metrics, error) {
+	district := &places.District{Postcode: "10961", District: "Friedrichshain-Kreuzberg"}
+	placeMap := make(places.PlaceMap)
+	for i, name := range names {
+		id := int64(i + 1)
+		placeMap[id] = &places.Place{
+			ID:         id,
+			Class:      places.StreetClass,
+			Name:       name,
+			District:   district,
+			Length:     100,
+			Lat:        52.5,
+			Lon:        13.4,
+			SimpleName: places.SanitizeString(name),
+			Phonetic:   places.ColognePhonetic(name),
+		}
+	}
+	return places.DistrictMap{district.Postcode: district}, placeMap, &places.Metrics{StreetCount: int32(len(placeMap))}, nil
+}
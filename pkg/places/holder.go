@@ -0,0 +1,59 @@
+package places
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Holder holds Places and allows to replace them (e.g. when the data changed)
+// while serving requests.
+type Holder struct {
+
+	// the config to initialize Places with
+	config Config
+
+	// the current places (i.e. *Places)
+	places atomic.Value
+
+	// serializes reloads
+	m sync.Mutex
+}
+
+// NewHolder initializes a new Holder holding Places initialized with the given data provider.
+func (config Config) NewHolder(dataProvider Provider) (*Holder, error) {
+	p, err := config.NewPlaces(dataProvider)
+	if err != nil {
+		return nil, err
+	}
+	h := &Holder{config: config}
+	h.places.Store(p)
+	return h, nil
+}
+
+// Places returns the current places.
+func (h *Holder) Places() *Places {
+	return h.places.Load().(*Places)
+}
+
+// Reload initializes new Places with the given data provider and, once done,
+// replaces the current ones. Relevance is carried over for places whose IDs
+// still exist. Until replaced, the current places keep serving requests. If
+// loading the data fails, the current places are kept. Otherwise, the cache of
+// the replaced places is closed.
+func (h *Holder) Reload(dataProvider Provider) error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	p, err := h.config.NewPlaces(dataProvider)
+	if err != nil {
+		return err
+	}
+	old := h.Places()
+	p.restoreRelevance(old.relevance())
+	h.places.Store(p)
+
+	// release the cache of the replaced places (requests still using them go uncached)
+	old.Close()
+
+	return nil
+}
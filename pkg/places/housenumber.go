@@ -0,0 +1,355 @@
+package places
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// HouseNumber is a parsed house number. That is, a list of single house numbers
+// (e.g. "3a") and ranges of house numbers (e.g. "3-5"), see ParseHouseNumber.
+type HouseNumber []HouseNumberRange
+
+// HouseNumberRange is a range of house numbers (e.g. "3-5" or "3a-c"). Single
+// house numbers are ranges with equal bounds.
+type HouseNumberRange struct {
+	from, to houseNumberBound
+}
+
+// houseNumberBound is a single house number (i.e. a number and a letter suffix, if any).
+type houseNumberBound struct {
+	number int
+	suffix string
+}
+
+// less compares house numbers (e.g. 3 < 3a < 4).
+func (b houseNumberBound) less(o houseNumberBound) bool {
+	if b.number != o.number {
+		return b.number < o.number
+	}
+	return b.suffix < o.suffix
+}
+
+// String returns the canonical form of the house number bound.
+func (b houseNumberBound) String() string {
+	return strconv.Itoa(b.number) + b.suffix
+}
+
+// String returns the canonical form of the range (e.g. "3-5", "3a-3c" or "3a").
+func (r HouseNumberRange) String() string {
+	if r.from == r.to {
+		return r.from.String()
+	}
+	return r.from.String() + "-" + r.to.String()
+}
+
+// contains returns true, if the given house number is within the range.
+func (r HouseNumberRange) contains(b houseNumberBound) bool {
+	return !b.less(r.from) && !r.to.less(b)
+}
+
+// String returns the canonical form of the house number. That is, lower case,
+// without white spaces and with ranges and lists separated by "-" and ","
+// (e.g. "3 A" becomes "3a" and "12 / 14" becomes "12,14").
+func (h HouseNumber) String() string {
+	parts := make([]string, len(h))
+	for i, r := range h {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Contains returns true, if all house numbers of the given house number are
+// within (any range of) this house number (e.g. "3-5" contains "4" and "4a").
+func (h HouseNumber) Contains(o HouseNumber) bool {
+	if len(o) == 0 {
+		return false
+	}
+	for _, or := range o {
+		if !h.containsBound(or.from) || !h.containsBound(or.to) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsBound returns true, if the given house number is within any range of this house number.
+func (h HouseNumber) containsBound(b houseNumberBound) bool {
+	for _, r := range h {
+		if r.contains(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseHouseNumber parses house numbers with letter suffixes (e.g. "3a" or "3
+// A"), ranges (e.g. "3-5" or "3a-c") and lists separated by "," or "/" (e.g.
+// "12/14"). Case and white spaces are ignored.
+func ParseHouseNumber(s string) (HouseNumber, error) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+	if s == "" {
+		return nil, fmt.Errorf("empty house number")
+	}
+	var h HouseNumber
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '/'
+	}) {
+		from, to, isRange := part, "", false
+		if i := strings.IndexByte(part, '-'); i >= 0 {
+			from, to, isRange = part[:i], part[i+1:], true
+		}
+		fromBound, err := parseHouseNumberBound(from)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse house number '%s': %w", s, err)
+		}
+		toBound := fromBound
+		if isRange {
+
+			// the upper bound might be a suffix only (e.g. the "c" in "3a-c")
+			if to != "" && strings.IndexFunc(to, unicode.IsDigit) == -1 {
+				to = strconv.Itoa(fromBound.number) + to
+			}
+			toBound, err = parseHouseNumberBound(to)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse house number '%s': %w", s, err)
+			}
+			if toBound.less(fromBound) {
+				fromBound, toBound = toBound, fromBound
+			}
+		}
+		h = append(h, HouseNumberRange{from: fromBound, to: toBound})
+	}
+	if len(h) == 0 {
+		return nil, fmt.Errorf("failed to parse house number '%s'", s)
+	}
+	return h, nil
+}
+
+// parseHouseNumberBound parses a single house number (e.g. "3a").
+func parseHouseNumberBound(s string) (houseNumberBound, error) {
+	n, suffix := splitHouseNumber(s)
+	if n < 0 {
+		return houseNumberBound{}, fmt.Errorf("missing number in '%s'", s)
+	}
+	for _, r := range suffix {
+		if !unicode.IsLetter(r) {
+			return houseNumberBound{}, fmt.Errorf("unexpected '%c' in '%s'", r, s)
+		}
+	}
+	return houseNumberBound{number: n, suffix: suffix}, nil
+}
+
+// NormalizeHouseNumber returns the canonical form of the given house number (see
+// HouseNumber.String). House numbers that can't be parsed are returned trimmed
+// and in lower case.
+func NormalizeHouseNumber(s string) string {
+	h, err := ParseHouseNumber(s)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(s))
+	}
+	return h.String()
+}
+
+// splitHouseNumber splits a house number into its leading number and the rest
+// (e.g. "12a" into 12 and "a"). House numbers without a leading number have a
+// number of -1.
+func splitHouseNumber(houseNumber string) (int, string) {
+	i := strings.IndexFunc(houseNumber, func(r rune) bool {
+		return !unicode.IsDigit(r)
+	})
+	if i == -1 {
+		i = len(houseNumber)
+	}
+	n, err := strconv.Atoi(houseNumber[:i])
+	if err != nil {
+		return -1, houseNumber
+	}
+	return n, houseNumber[i:]
+}
+
+// houseNumberLesser compares house numbers in natural order (e.g. 3 < 3a < 10).
+func houseNumberLesser(i, j string) bool {
+
+	// less by number
+	ni, ri := splitHouseNumber(i)
+	nj, rj := splitHouseNumber(j)
+	if ni != nj {
+		if ni < nj {
+			return true
+		} else {
+			return false
+		}
+	}
+
+	// less by rest (e.g. suffix)
+	ri = strings.ToLower(ri)
+	rj = strings.ToLower(rj)
+	if ri != rj {
+		if ri < rj {
+			return true
+		} else {
+			return false
+		}
+	}
+
+	return false
+}
+
+// CompleteHouseNumber returns the house numbers of the given street starting
+// with the given prefix (ignoring case and white spaces) in natural order (e.g.
+// 3 < 3a < 10). For locations, the house numbers of the location's street are
+// returned. CompleteHouseNumber returns nil, if there is no such street or
+// location.
+func (bp *Places) CompleteHouseNumber(ctx context.Context, streetID int64, prefix string) []*Place {
+	start := time.Now()
+	r := bp.completeHouseNumber(ctx, streetID, prefix)
+	go bp.updateMetrics(time.Since(start))
+	return r
+}
+
+func (bp *Places) completeHouseNumber(_ context.Context, streetID int64, prefix string) []*Place {
+
+	// get the street (of a location)
+	street, ok := bp.placesMap[streetID]
+	if !ok || street.Class == HouseNumberClass || street.Class == DistrictClass {
+		return nil
+	}
+	if street.Class == LocationClass {
+		street = street.Street
+		if street == nil {
+			return nil
+		}
+	}
+
+	// collect matching house numbers
+	prefix = strings.ToLower(strings.Join(strings.Fields(prefix), ""))
+	houses := []*Place{}
+	for _, house := range street.HouseNumbers {
+		if strings.HasPrefix(strings.ToLower(house.HouseNumber), prefix) {
+			houses = append(houses, house)
+		}
+	}
+
+	// sort in natural order
+	sort.SliceStable(houses, func(i, j int) bool {
+		return houseNumberLesser(houses[i].HouseNumber, houses[j].HouseNumber)
+	})
+
+	return houses
+}
+
+// houseNumberPosition is the position of a house number (see interpolateHouseNumber).
+type houseNumberPosition struct {
+	number int
+	house  *Place
+}
+
+// distinctPositions returns the given (sorted) positions without repeated
+// numbers (e.g. of "9" and "9a"), keeping the first position of each number.
+func distinctPositions(positions []houseNumberPosition) []houseNumberPosition {
+	var distinct []houseNumberPosition
+	for _, position := range positions {
+		if n := len(distinct); n > 0 && distinct[n-1].number == position.number {
+			continue
+		}
+		distinct = append(distinct, position)
+	}
+	return distinct
+}
+
+// interpolateHouseNumber estimates the position of the given (missing) house
+// number of the given street from the closest house numbers below and above on
+// the same side of the street (i.e. with the same parity). The position is
+// interpolated linearly and its accuracy is estimated as half the distance
+// between those house numbers. If there is only one side, the position is
+// extrapolated from the two closest house numbers on that side, but no further
+// than the distance between them. Only single house numbers (i.e. no ranges or
+// lists) are interpolated. interpolateHouseNumber returns nil, if there are not
+// enough house numbers to estimate the position from (or the house number is
+// too far beyond them).
+func interpolateHouseNumber(street *Place, h HouseNumber) *Place {
+	if len(h) != 1 || h[0].from != h[0].to {
+		return nil
+	}
+	n := h[0].from.number
+
+	// collect the positions of house numbers on the same side below and above
+	var below, above []houseNumberPosition
+	for _, house := range street.HouseNumbers {
+		for _, r := range house.houseNumber {
+			numbers := []int{r.from.number}
+			if r.to.number != r.from.number {
+				numbers = append(numbers, r.to.number)
+			}
+			for _, number := range numbers {
+				if number%2 != n%2 {
+					continue
+				}
+				if number < n {
+					below = append(below, houseNumberPosition{number: number, house: house})
+				} else if number > n {
+					above = append(above, houseNumberPosition{number: number, house: house})
+				}
+			}
+		}
+	}
+	sort.SliceStable(below, func(i, j int) bool {
+		return below[i].number > below[j].number
+	})
+	sort.SliceStable(above, func(i, j int) bool {
+		return above[i].number < above[j].number
+	})
+	below, above = distinctPositions(below), distinctPositions(above)
+
+	// pick the two positions to interpolate (or extrapolate) from
+	var a, b houseNumberPosition
+	switch {
+	case len(below) > 0 && len(above) > 0:
+		a, b = below[0], above[0]
+	case len(below) > 1:
+		a, b = below[1], below[0]
+	case len(above) > 1:
+		a, b = above[1], above[0]
+	default:
+		return nil
+	}
+
+	// interpolate linearly (by number), but don't extrapolate beyond one segment
+	t := float64(n-a.number) / float64(b.number-a.number)
+	if t < -1 || t > 2 {
+		return nil
+	}
+	lat := a.house.Lat + t*(b.house.Lat-a.house.Lat)
+	lon := a.house.Lon + t*(b.house.Lon-a.house.Lon)
+
+	// the accuracy is half the distance between the positions (or the distance to the closer one, if extrapolated)
+	accuracy := Haversine(a.house.Lat, a.house.Lon, b.house.Lat, b.house.Lon) / 2
+	if t < 0 || t > 1 {
+		accuracy = math.Max(accuracy, math.Min(
+			Haversine(lat, lon, a.house.Lat, a.house.Lon),
+			Haversine(lat, lon, b.house.Lat, b.house.Lon),
+		))
+	}
+
+	district := a.house.District
+	if math.Abs(t-1) < math.Abs(t) {
+		district = b.house.District
+	}
+	return &Place{
+		Class:        HouseNumberClass,
+		Street:       street,
+		HouseNumber:  h.String(),
+		District:     district,
+		Lat:          lat,
+		Lon:          lon,
+		Interpolated: true,
+		Accuracy:     accuracy,
+		houseNumber:  h,
+	}
+}
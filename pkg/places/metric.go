@@ -0,0 +1,162 @@
+package places
+
+import (
+	"fmt"
+	"github.com/agnivade/levenshtein"
+	"math"
+)
+
+// DistanceMetric enumerates the string distance metrics to compare inputs and names with.
+type DistanceMetric string
+
+const (
+
+	// LevenshteinMetric is the Levenshtein distance (i.e. the number of insertions,
+	// deletions and substitutions).
+	LevenshteinMetric DistanceMetric = "levenshtein"
+
+	// DamerauMetric is the Damerau-Levenshtein distance (optimal string alignment).
+	// That is, as LevenshteinMetric, but transpositions of adjacent runes (e.g.
+	// "Oranienbugrer") count as one edit.
+	DamerauMetric DistanceMetric = "damerau"
+
+	// JaroWinklerMetric is the Jaro-Winkler distance scaled to edits. That is,
+	// round((1 - similarity) * n), with n being the length of the longer string
+	// (but at least 1 for different strings). Jaro-Winkler favors common prefixes,
+	// but as similarities are rounded to edits, distances tie more often than with
+	// the other metrics.
+	JaroWinklerMetric DistanceMetric = "jarowinkler"
+)
+
+// distanceFunc computes the distance between two strings.
+type distanceFunc func(a, b string) int
+
+// distanceFuncOf returns the distance function of the given metric (defaults to LevenshteinMetric).
+func distanceFuncOf(metric DistanceMetric) (distanceFunc, error) {
+	switch metric {
+	case "", LevenshteinMetric:
+		return levenshtein.ComputeDistance, nil
+	case DamerauMetric:
+		return damerauDistance, nil
+	case JaroWinklerMetric:
+		return jaroWinklerDistance, nil
+	default:
+		return nil, fmt.Errorf("unknown distance metric '%s'", metric)
+	}
+}
+
+// damerauDistance computes the Damerau-Levenshtein distance (optimal string
+// alignment) between a and b.
+func damerauDistance(a, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	// the last three rows of the distance matrix suffice
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = Min(Min(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = Min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(rb)]
+}
+
+// jaroWinklerDistance computes the Jaro-Winkler distance between a and b scaled
+// to edits (see JaroWinklerMetric).
+func jaroWinklerDistance(a, b string) int {
+	if a == b {
+		return 0
+	}
+	ra := []rune(a)
+	rb := []rune(b)
+	n := len(ra)
+	if len(rb) > n {
+		n = len(rb)
+	}
+	d := int(math.Round((1 - jaroWinkler(ra, rb)) * float64(n)))
+	if d < 1 {
+		return 1
+	}
+	return d
+}
+
+// jaroWinkler computes the Jaro-Winkler similarity (between 0 and 1) of a and b.
+func jaroWinkler(a, b []rune) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		if len(a) == len(b) {
+			return 1
+		}
+		return 0
+	}
+
+	// runes match if equal and not farther apart than window
+	window := len(a)
+	if len(b) > window {
+		window = len(b)
+	}
+	window = window/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matchedA := make([]bool, len(a))
+	matchedB := make([]bool, len(b))
+	matches := 0
+	for i := range a {
+		lo := i - window
+		if lo < 0 {
+			lo = 0
+		}
+		hi := Min(i+window+1, len(b))
+		for j := lo; j < hi; j++ {
+			if !matchedB[j] && a[i] == b[j] {
+				matchedA[i] = true
+				matchedB[j] = true
+				matches += 1
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	// count transpositions (i.e. matching runes in different order)
+	transpositions := 0
+	j := 0
+	for i := range a {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j += 1
+		}
+		if a[i] != b[j] {
+			transpositions += 1
+		}
+		j += 1
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions)/2)/m) / 3
+
+	// boost common prefixes (of up to 4 runes)
+	prefix := 0
+	for prefix < Min(4, Min(len(a), len(b))) && a[prefix] == b[prefix] {
+		prefix += 1
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
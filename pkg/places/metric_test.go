@@ -0,0 +1,85 @@
+package places_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/heimdalr/berlinplaces/pkg/places"
+	"testing"
+)
+
+func TestPlaces_DistanceMetric(t *testing.T) {
+	tests := []struct {
+		metric       places.DistanceMetric
+		text         string
+		want         string
+		wantDistance int
+	}{
+		{metric: places.LevenshteinMetric, text: "Oranienbugrerstraße", want: "Oranienburgerstraße", wantDistance: 2},
+		{metric: places.DamerauMetric, text: "Oranienbugrerstraße", want: "Oranienburgerstraße", wantDistance: 1},
+		{metric: places.JaroWinklerMetric, text: "Oranienburgerstraße", want: "Oranienburgerstraße", wantDistance: 0},
+		{metric: places.DamerauMetric, text: "Ora", want: "Oranienweg", wantDistance: 7},
+		{metric: places.JaroWinklerMetric, text: "Ora", want: "Oraniengasse", wantDistance: 2},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.metric, tt.text), func(t *testing.T) {
+			config := *places.DefaultConfig
+			config.DistanceMetric = tt.metric
+			p, err := config.NewPlaces(syntheticProvider{})
+			if err != nil {
+				t.Fatal(fmt.Errorf("failed to init places: %w", err))
+			}
+			r := p.GetCompletions(context.Background(), places.Query{Text: tt.text})
+			for _, result := range r {
+				if result.Place.Name == tt.want {
+					if result.Distance != tt.wantDistance {
+						t.Errorf("got distance %d, want %d", result.Distance, tt.wantDistance)
+					}
+					return
+				}
+			}
+			t.Errorf("got %v, want %s among results", r, tt.want)
+		})
+	}
+
+	// unknown metrics are rejected
+	config := *places.DefaultConfig
+	config.DistanceMetric = "hamming"
+	if _, err := config.NewPlaces(syntheticProvider{}); err == nil {
+		t.Errorf("got no error for unknown metric")
+	}
+}
+
+func TestPlaces_DistanceMetricTokens(t *testing.T) {
+
+	// "kastanien" is two substitutions from "Kastenian" and one transposition
+	// (i.e. two Levenshtein edits) from "Katsanien", thus it is assigned to the
+	// first token with LevenshteinMetric but to the second one with DamerauMetric
+	tests := []struct {
+		metric    places.DistanceMetric
+		wantSpans []places.Span
+	}{
+		{metric: places.LevenshteinMetric, wantSpans: []places.Span{{Start: 0, End: 3}, {Start: 4, End: 8}, {Start: 9, End: 11}, {Start: 12, End: 13}}},
+		{metric: places.DamerauMetric, wantSpans: []places.Span{{Start: 0, End: 3}, {Start: 14, End: 16}, {Start: 18, End: 23}}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.metric), func(t *testing.T) {
+			config := *places.DefaultConfig
+			config.DistanceMetric = tt.metric
+			config.TokenMatching = true
+			p, err := config.NewPlaces(namesProvider{"Weg Kastenian Katsanien"})
+			if err != nil {
+				t.Fatal(fmt.Errorf("failed to init places: %w", err))
+			}
+			r := p.GetCompletions(context.Background(), places.Query{Text: "kastanien weg"})
+			if len(r) < 1 || r[0].Place == nil || r[0].Place.ID != 1 {
+				t.Fatalf("got %v, want street first", r)
+			}
+			if r[0].Match != places.TokenMatch {
+				t.Fatalf("got match %v, want %v", r[0].Match, places.TokenMatch)
+			}
+			if fmt.Sprint(r[0].Spans) != fmt.Sprint(tt.wantSpans) {
+				t.Errorf("got %v, want %v", r[0].Spans, tt.wantSpans)
+			}
+		})
+	}
+}
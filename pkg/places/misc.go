@@ -1,23 +1,51 @@
 package places
 
 import (
+	"golang.org/x/text/unicode/norm"
 	"strings"
 	"unicode"
 )
 
-// SanitizeString to unicode letters, spaces and minus
+// SanitizeString to folded unicode letters and digits (see foldRune).
 func SanitizeString(s string) string {
 
-	// only unicode letters, spaces and minus
-	s = strings.Map(func(r rune) rune {
-		if unicode.IsLetter(r) {
-			return r
-		}
-		return -1
-	}, s)
+	// only (folded) unicode letters and digits
+	var b strings.Builder
+	for _, r := range norm.NFC.String(s) {
+		b.WriteString(foldRune(r))
+	}
+	return b.String()
+}
 
-	// remove spaces and minus from head and tail and lower case
-	return strings.ToLower(strings.Trim(s, " -"))
+// foldRune returns the folded (lower case) form of the given rune. That is,
+// umlauts and ß are transcribed (e.g. "ö" to "oe"), other letters are
+// decomposed (NFKD) with combining marks removed (e.g. "é" to "e"), digits are
+// kept (e.g. the "17" in "Straße des 17. Juni") and all other runes are
+// dropped.
+func foldRune(r rune) string {
+	if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return ""
+	}
+	var b strings.Builder
+	for _, lr := range strings.ToLower(string(r)) {
+		switch lr {
+		case 'ä':
+			b.WriteString("ae")
+		case 'ö':
+			b.WriteString("oe")
+		case 'ü':
+			b.WriteString("ue")
+		case 'ß':
+			b.WriteString("ss")
+		default:
+			for _, dr := range norm.NFKD.String(string(lr)) {
+				if (unicode.IsLetter(dr) || unicode.IsDigit(dr)) && !unicode.Is(unicode.Mn, dr) {
+					b.WriteRune(unicode.ToLower(dr))
+				}
+			}
+		}
+	}
+	return b.String()
 }
 
 // Min returns the minimum of a and b.
@@ -28,6 +56,14 @@ func Min(a, b int) int {
 	return b
 }
 
+// Max returns the maximum of a and b.
+func Max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 // Abs returns the absolute value of x.
 func Abs(x int) int {
 	if x < 0 {
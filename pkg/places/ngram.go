@@ -0,0 +1,97 @@
+package places
+
+import (
+	"strings"
+)
+
+// ngramLength is the length of the n-grams in the n-gram index (i.e. trigrams).
+const ngramLength = 3
+
+// ngrams returns the (distinct) n-grams of the given string.
+func ngrams(s string) []string {
+	runes := []rune(s)
+	seen := make(map[string]struct{})
+	var grams []string
+	for i := 0; i+ngramLength <= len(runes); i++ {
+		gram := string(runes[i : i+ngramLength])
+		if _, ok := seen[gram]; !ok {
+			seen[gram] = struct{}{}
+			grams = append(grams, gram)
+		}
+	}
+	return grams
+}
+
+// computeNgramIndex maps the given places by the n-grams of their simple names.
+func computeNgramIndex(streetsAndLocations []*Place) map[string][]*Place {
+	index := make(map[string][]*Place)
+	for _, p := range streetsAndLocations {
+		for _, gram := range ngrams(p.SimpleName) {
+			index[gram] = append(index[gram], p)
+		}
+	}
+	return index
+}
+
+// infixPlaces returns the places whose simple name contains the given input.
+func (bp *Places) infixPlaces(simpleInput string) []*Place {
+
+	grams := ngrams(simpleInput)
+	if len(grams) == 0 {
+		return nil
+	}
+
+	// places containing the input contain all of its n-grams, thus it suffices to check those of the rarest n-gram
+	rarest := bp.ngramIndex[grams[0]]
+	for _, gram := range grams[1:] {
+		if posting := bp.ngramIndex[gram]; len(posting) < len(rarest) {
+			rarest = posting
+		}
+	}
+
+	var places []*Place
+	for _, p := range rarest {
+		if strings.Contains(p.SimpleName, simpleInput) {
+			places = append(places, p)
+		}
+	}
+	return places
+}
+
+// withInfixCandidates adds the places containing the given input to the given
+// places (if infix matching is enabled). As short inputs are contained in
+// too many names, inputs shorter than MinLev are ignored.
+func (bp *Places) withInfixCandidates(places []*Place, simpleInput string) []*Place {
+
+	// if disabled or if we already consider all places, there is nothing to add
+	if !bp.config.InfixMatching || len(places) == len(bp.streetsAndLocations) {
+		return places
+	}
+	if len([]rune(simpleInput)) < bp.config.MinLev {
+		return places
+	}
+
+	infixPlaces := bp.infixPlaces(simpleInput)
+	if len(infixPlaces) == 0 {
+		return places
+	}
+
+	// copy, as places may be shared
+	candidates := make([]*Place, len(places), len(places)+len(infixPlaces))
+	copy(candidates, places)
+
+	return deDuplicate(append(candidates, infixPlaces...))
+}
+
+// matchTier returns the tier of the given result for infix matching. That is,
+// prefix matches rank above infix matches which rank above all other matches.
+func matchTier(r *Result) int {
+	switch r.Match {
+	case PrefixMatch:
+		return 0
+	case InfixMatch:
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,148 @@
+package places
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRankingLength is the maximum number of results ranked for paginated queries.
+const maxRankingLength = 1000
+
+// maxRankingCount is the maximum number of rankings kept for paginated queries.
+const maxRankingCount = 1000
+
+// ranking is a snapshot of the ranked results of a (paginated) query.
+type ranking struct {
+	key     string
+	results []*Result
+	expires time.Time
+}
+
+// rankings holds snapshots of rankings mapped by query key. As rankings are
+// kept unchanged until they expire, pages of the same query stay consistent
+// even if relevance changes in the meantime. At most maxRankingCount rankings
+// are kept, evicting the least recently used ones.
+type rankings struct {
+	m        sync.Mutex
+	ttl      time.Duration
+	capacity int
+
+	// rankings mapped by key to their element in lru
+	entries map[string]*list.Element
+
+	// rankings from the most to the least recently used one
+	lru *list.List
+}
+
+// newRankings returns an empty ranking store with the given TTL and capacity.
+func newRankings(ttl time.Duration, capacity int) *rankings {
+	return &rankings{ttl: ttl, capacity: capacity, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// get returns the (unexpired) ranking for the given key (if any).
+func (rs *rankings) get(key string) ([]*Result, bool) {
+	rs.m.Lock()
+	defer rs.m.Unlock()
+	e, ok := rs.entries[key]
+	if !ok {
+		return nil, false
+	}
+	r := e.Value.(*ranking)
+	if !time.Now().Before(r.expires) {
+		rs.remove(e)
+		return nil, false
+	}
+	rs.lru.MoveToFront(e)
+	return r.results, true
+}
+
+// set stores the given ranking (and evicts the least recently used ones beyond capacity).
+func (rs *rankings) set(key string, results []*Result) {
+	if rs.ttl <= 0 || rs.capacity <= 0 {
+		return
+	}
+	rs.m.Lock()
+	defer rs.m.Unlock()
+	r := &ranking{key: key, results: results, expires: time.Now().Add(rs.ttl)}
+	if e, ok := rs.entries[key]; ok {
+		e.Value = r
+		rs.lru.MoveToFront(e)
+		return
+	}
+	rs.entries[key] = rs.lru.PushFront(r)
+	for rs.lru.Len() > rs.capacity {
+		rs.remove(rs.lru.Back())
+	}
+}
+
+// remove removes the given element (the caller must hold the lock).
+func (rs *rankings) remove(e *list.Element) {
+	rs.lru.Remove(e)
+	delete(rs.entries, e.Value.(*ranking).key)
+}
+
+// paginated returns true, if the query asks for a specific page of results.
+func (q Query) paginated() bool {
+	return q.Limit > 0 || q.Offset > 0
+}
+
+// limit returns the number of results per page (i.e. Limit or, if not given, the given default).
+func (q Query) limit(defaultLimit int) int {
+	if q.Limit <= 0 {
+		return defaultLimit
+	}
+	return q.Limit
+}
+
+// key returns a key identifying the ranking of the query (i.e. ignoring Limit and Offset).
+func (q Query) key() string {
+	var b strings.Builder
+	b.WriteString(q.Text)
+	if q.Near != nil {
+		_, _ = fmt.Fprintf(&b, "|near:%f,%f", q.Near.Lat, q.Near.Lon)
+	}
+	if q.Bias != nil {
+		_, _ = fmt.Fprintf(&b, "|bias:%f", *q.Bias)
+	}
+	_, _ = fmt.Fprintf(&b, "|class:%v|type:%q|district:%q|postcode:%q", q.Classes, q.Types, q.Districts, q.Postcodes)
+	return b.String()
+}
+
+// pagedCompletions returns the requested page of results. Pages are cut from a
+// snapshot of the ranking (taken when first requested and kept for
+// PaginationTTL), such that subsequent pages neither skip nor repeat results.
+func (bp *Places) pagedCompletions(ctx context.Context, query Query) []*Result {
+
+	// get the ranking from the snapshot or compute it
+	key := query.key()
+	results, ok := bp.rankings.get(key)
+	if !ok {
+		results = bp.getCompletions(ctx, query)
+
+		// don't keep partial rankings (i.e. if the lookup was interrupted)
+		if ctx.Err() == nil {
+			bp.rankings.set(key, results)
+		}
+	}
+
+	// cut the page
+	limit := query.limit(bp.config.MinCompletionCount)
+	if query.Offset >= len(results) {
+		return []*Result{}
+	}
+	return results[query.Offset:Min(query.Offset+limit, len(results))]
+}
+
+// truncateQuery truncates the given (sorted) results for the given query. That
+// is, for paginated queries, up to maxRankingLength results are kept (to cut
+// pages from) and otherwise results are truncated as usual.
+func (bp *Places) truncateQuery(results []*Result, simpleInput string, query Query) []*Result {
+	if query.paginated() {
+		return results[:Min(maxRankingLength, len(results))]
+	}
+	return bp.truncate(results, simpleInput)
+}
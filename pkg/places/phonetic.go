@@ -0,0 +1,185 @@
+package places
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minPhoneticLength is the minimum length of the phonetic code of an input before considering phonetic matches.
+const minPhoneticLength = 2
+
+// ColognePhonetic returns the phonetic code of the given string according to
+// the Kölner Phonetik (Cologne phonetics, which suits German names).
+// See: https://de.wikipedia.org/wiki/K%C3%B6lner_Phonetik
+func ColognePhonetic(s string) string {
+
+	// only (upper case) letters, with umlauts and ß mapped to their base letters
+	var letters []rune
+	for _, r := range strings.ToUpper(s) {
+		switch r {
+		case 'Ä':
+			r = 'A'
+		case 'Ö':
+			r = 'O'
+		case 'Ü':
+			r = 'U'
+		case 'ß':
+			r = 'S'
+		}
+		if unicode.IsLetter(r) {
+			letters = append(letters, r)
+		}
+	}
+
+	// encode letter by letter (considering neighbours)
+	var codes []byte
+	for i, r := range letters {
+		var prev, next rune
+		if i > 0 {
+			prev = letters[i-1]
+		}
+		if i < len(letters)-1 {
+			next = letters[i+1]
+		}
+		switch r {
+		case 'A', 'E', 'I', 'J', 'O', 'U', 'Y':
+			codes = append(codes, '0')
+		case 'B':
+			codes = append(codes, '1')
+		case 'P':
+			if next == 'H' {
+				codes = append(codes, '3')
+			} else {
+				codes = append(codes, '1')
+			}
+		case 'D', 'T':
+			if strings.ContainsRune("CSZ", next) {
+				codes = append(codes, '8')
+			} else {
+				codes = append(codes, '2')
+			}
+		case 'F', 'V', 'W':
+			codes = append(codes, '3')
+		case 'G', 'K', 'Q':
+			codes = append(codes, '4')
+		case 'C':
+			if i == 0 {
+				if strings.ContainsRune("AHKLOQRUX", next) {
+					codes = append(codes, '4')
+				} else {
+					codes = append(codes, '8')
+				}
+			} else if strings.ContainsRune("AHKOQUX", next) && !strings.ContainsRune("SZ", prev) {
+				codes = append(codes, '4')
+			} else {
+				codes = append(codes, '8')
+			}
+		case 'X':
+			if strings.ContainsRune("CKQ", prev) {
+				codes = append(codes, '8')
+			} else {
+				codes = append(codes, '4', '8')
+			}
+		case 'L':
+			codes = append(codes, '5')
+		case 'M', 'N':
+			codes = append(codes, '6')
+		case 'R':
+			codes = append(codes, '7')
+		case 'S', 'Z':
+			codes = append(codes, '8')
+		}
+	}
+
+	// collapse repeated codes and remove zeros (except at the beginning)
+	var code []byte
+	for i, c := range codes {
+		if i > 0 && c == codes[i-1] {
+			continue
+		}
+		if c == '0' && i > 0 {
+			continue
+		}
+		code = append(code, c)
+	}
+
+	return string(code)
+}
+
+// computePhoneticIndex returns the given places (with a phonetic code) sorted by their phonetic code.
+func computePhoneticIndex(streetsAndLocations []*Place) []*Place {
+	var index []*Place
+	for _, p := range streetsAndLocations {
+		if p.Phonetic != "" {
+			index = append(index, p)
+		}
+	}
+	sort.SliceStable(index, func(i, j int) bool {
+		return index[i].Phonetic < index[j].Phonetic
+	})
+	return index
+}
+
+// phoneticPlaces returns all places whose phonetic code starts with the given code.
+func (bp *Places) phoneticPlaces(code string) []*Place {
+	lo := sort.Search(len(bp.phoneticIndex), func(i int) bool {
+		return bp.phoneticIndex[i].Phonetic >= code
+	})
+	hi := lo + sort.Search(len(bp.phoneticIndex)-lo, func(i int) bool {
+		return !strings.HasPrefix(bp.phoneticIndex[lo+i].Phonetic, code)
+	})
+	return bp.phoneticIndex[lo:hi]
+}
+
+// withPhoneticResults is the fallback for inputs not being a prefix of any of
+// the given results (i.e. inputs likely to be misspelled). withPhoneticResults
+// adds places that sound like the input (i.e. whose phonetic code starts with
+// the phonetic code of the input) to the results. As such places are treated as
+// if they were spelled like the input, their distance is the length of the
+// remainder (but at least 1, as they aren't exact matches).
+func (bp *Places) withPhoneticResults(results []*Result, simpleInput string, query Query) []*Result {
+
+	inputLength := len([]rune(simpleInput))
+	if inputLength < bp.config.MinLev {
+		return results
+	}
+
+	// if the input is a prefix (or an infix) of any result, no need to fall back
+	for _, r := range results {
+		if strings.HasPrefix(r.Place.SimpleName, simpleInput) || r.Match == InfixMatch {
+			return results
+		}
+	}
+
+	code := ColognePhonetic(simpleInput)
+	if len(code) < minPhoneticLength {
+		return results
+	}
+
+	// index the results by place
+	resultsMap := make(map[*Place]*Result, len(results))
+	for _, r := range results {
+		resultsMap[r.Place] = r
+	}
+
+	for _, p := range bp.phoneticPlaces(code) {
+		if !query.matches(p) {
+			continue
+		}
+		distance := Abs(len([]rune(p.SimpleName)) - inputLength)
+		if distance < 1 {
+			distance = 1
+		}
+		if r, ok := resultsMap[p]; ok {
+			if distance < r.Distance {
+				r.Distance = distance
+				r.Match = PhoneticMatch
+			}
+		} else {
+			results = append(results, &Result{Distance: distance, Place: p, Match: PhoneticMatch})
+		}
+	}
+
+	return results
+}
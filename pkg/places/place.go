@@ -1,10 +1,14 @@
 package places
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
 
 type District struct {
-	Postcode string
-	District string
+	Postcode string `json:"postcode"`
+	District string `json:"district"`
 }
 
 type DistrictMap map[string]*District
@@ -22,11 +26,27 @@ const (
 
 	// HouseNumberClass is the place class of house numbers / buildings.
 	HouseNumberClass
+
+	// DistrictClass is the place class of districts.
+	DistrictClass
 )
 
+// classNames are the names of the place classes (indexed by class).
+var classNames = [...]string{"street", "location", "houseNumber", "district"}
+
 // String implements the stringer interface for Class.
 func (c Class) String() string {
-	return [...]string{"street", "location", "houseNumber"}[c]
+	return classNames[c]
+}
+
+// ParseClass returns the class with the given name.
+func ParseClass(s string) (Class, error) {
+	for c, name := range classNames {
+		if name == s {
+			return Class(c), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown class '%s'", s)
 }
 
 type Place struct {
@@ -42,7 +62,29 @@ type Place struct {
 	Lon          float64
 	Relevance    uint64
 	SimpleName   string
+	Phonetic     string
 	HouseNumbers []*Place
+
+	// Interpolated is true for house numbers missing in the data, whose position
+	// is estimated from neighbouring house numbers (see GetPlace). Interpolated
+	// house numbers have no ID (i.e. 0).
+	Interpolated bool
+
+	// Accuracy is the estimated accuracy (in meters) of the position of
+	// interpolated house numbers.
+	Accuracy float64
+
+	// the (sanitized) tokens of the name (if token matching is enabled)
+	tokens []string
+
+	// the bits of the (log) decayed relevance score (see addScore)
+	score uint64
+
+	// the parsed house number (of house numbers and locations, see ParseHouseNumber)
+	houseNumber HouseNumber
+
+	// the locations with house numbers on the street (of streets)
+	locations []*Place
 }
 
 type PlaceMap map[int64]*Place
@@ -53,6 +95,7 @@ func (p *Place) MarshalJSON() ([]byte, error) {
 		streetName, postcode, district string
 		streetID                       *int64
 		length                         *int
+		accuracy                       *float64
 	)
 	if p.District != nil {
 		postcode = p.District.Postcode
@@ -64,38 +107,46 @@ func (p *Place) MarshalJSON() ([]byte, error) {
 	case LocationClass:
 		streetName = p.Street.Name
 		streetID = &p.Street.ID
+	case DistrictClass:
 	default: // HouseNumberClass
 		streetName = p.Street.Name
 		streetID = &p.Street.ID
+		if p.Interpolated {
+			accuracy = &p.Accuracy
+		}
 	}
 	return json.Marshal(&struct {
-		ID          int64   `json:"id"`
-		Class       string  `json:"class"`
-		Type        string  `json:"type,omitempty"`
-		Name        string  `json:"name,omitempty"`
-		Street      string  `json:"street,omitempty"`
-		StreetID    *int64  `json:"streetID,omitempty"`
-		HouseNumber string  `json:"houseNumber,omitempty"`
-		Postcode    string  `json:"postcode"`
-		District    string  `json:"district"`
-		Length      *int    `json:"length,omitempty"`
-		Lat         float64 `json:"lat"`
-		Lon         float64 `json:"lon"`
-		Relevance   uint64  `json:"relevance"`
+		ID           int64    `json:"id"`
+		Class        string   `json:"class"`
+		Type         string   `json:"type,omitempty"`
+		Name         string   `json:"name,omitempty"`
+		Street       string   `json:"street,omitempty"`
+		StreetID     *int64   `json:"streetID,omitempty"`
+		HouseNumber  string   `json:"houseNumber,omitempty"`
+		Postcode     string   `json:"postcode"`
+		District     string   `json:"district"`
+		Length       *int     `json:"length,omitempty"`
+		Lat          float64  `json:"lat"`
+		Lon          float64  `json:"lon"`
+		Relevance    uint64   `json:"relevance"`
+		Interpolated bool     `json:"interpolated,omitempty"`
+		Accuracy     *float64 `json:"accuracy,omitempty"`
 	}{
-		ID:          p.ID,
-		Class:       p.Class.String(),
-		Type:        p.Type,
-		Name:        p.Name,
-		Street:      streetName,
-		StreetID:    streetID,
-		HouseNumber: p.HouseNumber,
-		Postcode:    postcode,
-		District:    district,
-		Length:      length,
-		Lat:         p.Lat,
-		Lon:         p.Lon,
-		Relevance:   p.Relevance,
+		ID:           p.ID,
+		Class:        p.Class.String(),
+		Type:         p.Type,
+		Name:         p.Name,
+		Street:       streetName,
+		StreetID:     streetID,
+		HouseNumber:  p.HouseNumber,
+		Postcode:     postcode,
+		District:     district,
+		Length:       length,
+		Lat:          p.Lat,
+		Lon:          p.Lon,
+		Relevance:    atomic.LoadUint64(&p.Relevance),
+		Interpolated: p.Interpolated,
+		Accuracy:     accuracy,
 	})
 }
 
@@ -2,15 +2,20 @@ package places
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/agnivade/levenshtein"
 	"github.com/dgraph-io/ristretto"
+	"math"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+// cancellationInterval is the number of places to compute distances for between checks for cancellation.
+const cancellationInterval = 256
+
 // Config is the configuration for Places.
 type Config struct {
 
@@ -31,6 +36,61 @@ type Config struct {
 	// Duration to wait before evicting cache entries (in order to consider
 	// potentially changed relevance values).
 	CacheTTL time.Duration `json:"cacheTTL"`
+
+	// ProximityBias is the default strength of the proximity bias for queries with
+	// a reference point. The distance to the reference point adds
+	// ProximityBias * log2(1 + km) to the edit distance used in ranking. With a
+	// bias of 0, proximity is only used to break ties.
+	ProximityBias float64 `json:"proximityBias"`
+
+	// TokenMatching enables token-aware matching. That is, names and inputs are
+	// (additionally) compared token by token (in any order) and results covering
+	// all input tokens are ranked higher.
+	TokenMatching bool `json:"tokenMatching"`
+
+	// FuzzyDistance is the maximum edit distance between the (max) prefix of an
+	// input and (max) prefixes of names considered via the fuzzy index, when the
+	// prefix of the input is unknown (i.e. in case of early typos). The distances
+	// of these candidates bound a search over all names (walked as a trie), such
+	// that results are identical to those of a full scan. If results can't be
+	// bound (e.g. in case of tokens or custom rankings) or with a FuzzyDistance of
+	// 0, all streets and locations are considered (i.e. full scan).
+	FuzzyDistance int `json:"fuzzyDistance"`
+
+	// InfixMatching enables infix matching. That is, places whose name contains
+	// the input (e.g. "burger" in "Hamburger Platz") are considered via a trigram
+	// index and ranked below prefix matches but above other (fuzzy) matches.
+	InfixMatching bool `json:"infixMatching"`
+
+	// PaginationTTL is the duration to keep the ranking of a paginated query. Pages
+	// of the same query requested within PaginationTTL are cut from the same
+	// ranking (regardless of changes in relevance). The rankings of (at most) the
+	// 1000 most recently paginated queries are kept.
+	PaginationTTL time.Duration `json:"paginationTTL"`
+
+	// LatencyBudget is the maximum duration of computing completions. If exceeded,
+	// only prefix matches are returned (flagged as partial). With a LatencyBudget of
+	// 0, there is no limit.
+	LatencyBudget time.Duration `json:"latencyBudget"`
+
+	// RelevanceHalfLife is the half-life of hits in ranking by relevance. That is,
+	// places are ranked by a score to which each hit contributes 1 at first,
+	// halving with each RelevanceHalfLife passed. With a RelevanceHalfLife of 0,
+	// hits don't decay (i.e. places are ranked by their number of hits).
+	RelevanceHalfLife time.Duration `json:"relevanceHalfLife"`
+
+	// RankingWeights (if any) switch from the default ranking to ranking by a
+	// weighted score (see RankingWeights and ParseRankingWeights).
+	RankingWeights *RankingWeights `json:"rankingWeights,omitempty"`
+
+	// DistanceMetric is the metric to compute distances between inputs and names
+	// with (defaults to LevenshteinMetric). Distances of all metrics are in edits,
+	// thus DistanceCut, FuzzyDistance etc. apply regardless of the metric.
+	DistanceMetric DistanceMetric `json:"distanceMetric"`
+
+	// Ranker (if any) is a custom Ranker to use instead of the default (or
+	// weighted) one.
+	Ranker Ranker `json:"-"`
 }
 
 // DefaultConfig is the default configuration for Places.
@@ -40,12 +100,17 @@ var DefaultConfig = &Config{
 	MinLev:             4,
 	DistanceCut:        4,
 	CacheTTL:           300 * time.Second,
+	ProximityBias:      1,
+	FuzzyDistance:      2,
+	PaginationTTL:      300 * time.Second,
+	DistanceMetric:     LevenshteinMetric,
 }
 
 // Metrics is the type to sore metrics.
 type Metrics struct {
 	StreetCount      int32         `json:"streetCount"`
 	LocationCount    int32         `json:"locationCount"`
+	DistrictCount    int32         `json:"districtCount"`
 	HouseNumberCount int32         `json:"houseNumberCount"`
 	PrefixCount      int           `json:"prefixCount"`
 	QueryCount       int64         `json:"queryCount"`
@@ -65,17 +130,54 @@ type Places struct {
 	// districts mapped by postcode
 	districtsMap map[string]*District
 
+	// districts sorted by postcode (needed for postcode completion)
+	postcodes []*District
+
 	// places mapped by place ID
 	placesMap map[int64]*Place
 
-	// a sorted slice of street- and location places (needed for completion-computation)
+	// a sorted slice of street-, location- and district places (needed for completion-computation)
 	streetsAndLocations []*Place
 
-	// precomputed completions mapped by a prefix
-	prefixCompletions map[string]*completion
+	// street- and location places in lexical order of their simple names (needed for prefix ranges)
+	lexicalOrder []*Place
+
+	// precomputed completions mapped by a prefix (the map is read only, see prefixEntry)
+	prefixCompletions map[string]*prefixEntry
+
+	// serializes updates of prefix completions (see updateCompletions)
+	updateMutex sync.Mutex
+
+	// (max) prefixes mapped by their deletion variants (needed for early typos)
+	fuzzyIndex map[string][]string
+
+	// simple names of street- and location places walked as a trie (needed for early typos)
+	fuzzyTrie *fuzzyTrie
+
+	// street- and location places mapped by prefixes of their name tokens (if token matching is enabled)
+	tokenIndex map[string][]*Place
+
+	// street- and location places mapped by the trigrams of their simple names (if infix matching is enabled)
+	ngramIndex map[string][]*Place
+
+	// street- and location places sorted by their phonetic code (needed for phonetic matching)
+	phoneticIndex []*Place
 
-	// cache for longer prefixes and prefixes with typo
-	cache *ristretto.Cache
+	// spatial index over house numbers and locations (needed for reverse geocoding)
+	spatialIndex *kdTree
+
+	// cache for longer prefixes and prefixes with typo (nil once closed, see Close)
+	cacheMutex sync.RWMutex
+	cache      *ristretto.Cache
+
+	// snapshots of the rankings of paginated queries
+	rankings *rankings
+
+	// the ranker to sort results with
+	ranker Ranker
+
+	// the distance function (see DistanceMetric)
+	distance distanceFunc
 }
 
 type Provider interface {
@@ -89,28 +191,81 @@ func (config Config) NewPlaces(dataProvider Provider) (*Places, error) {
 	if dataProvider == nil {
 		return nil, fmt.Errorf("data provider must not be nil")
 	}
+	distance, errMetric := distanceFuncOf(config.DistanceMetric)
+	if errMetric != nil {
+		return nil, errMetric
+	}
 	districtsMap, placesMap, metrics, errData := dataProvider.Get()
 	if errData != nil {
 		return nil, errData
 	}
 
-	// collect streets and locations into a slice and then sort it
-	streetsAndLocations := make([]*Place, metrics.StreetCount+metrics.LocationCount)
-	i := 0
+	// parse house numbers (needed for matching ranges) and link locations with house numbers to their streets
 	for _, place := range placesMap {
-		if place.Class == StreetClass || place.Class == LocationClass {
-			streetsAndLocations[i] = place
-			i += 1
+		if place.Class == StreetClass {
+			place.locations = nil
+		}
+	}
+	for _, place := range placesMap {
+		if place.Class == HouseNumberClass {
+			place.houseNumber, _ = ParseHouseNumber(place.HouseNumber)
+		}
+		if place.Class == LocationClass && place.Street != nil && place.HouseNumber != "" {
+			place.houseNumber, _ = ParseHouseNumber(place.HouseNumber)
+			place.Street.locations = append(place.Street.locations, place)
+		}
+	}
+
+	// add districts as places
+	districtPlaces := computeDistrictPlaces(districtsMap, placesMap)
+	for _, place := range districtPlaces {
+		placesMap[place.ID] = place
+	}
+	metrics.DistrictCount = int32(len(districtPlaces))
+
+	// collect streets, locations and districts into a slice and then sort it
+	streetsAndLocations := make([]*Place, 0, metrics.StreetCount+metrics.LocationCount+metrics.DistrictCount)
+	for _, place := range placesMap {
+		if place.Class == StreetClass || place.Class == LocationClass || place.Class == DistrictClass {
+			streetsAndLocations = append(streetsAndLocations, place)
 		}
 	}
 	sort.Slice(streetsAndLocations, func(i, j int) bool {
 		return placeLesser(streetsAndLocations[i], streetsAndLocations[j])
 	})
 
+	// collect streets and locations in lexical order
+	lexicalOrder := make([]*Place, len(streetsAndLocations))
+	copy(lexicalOrder, streetsAndLocations)
+	sort.SliceStable(lexicalOrder, func(i, j int) bool {
+		return lexicalOrder[i].SimpleName < lexicalOrder[j].SimpleName
+	})
+
 	// compute prefix completions
 	prefixCompletions := computePrefixCompletions(streetsAndLocations, config.MaxPrefixLength, config.MaxPrefixLength)
 	metrics.PrefixCount = len(prefixCompletions)
 
+	// compute the token index (if needed)
+	var tokenIndex map[string][]*Place
+	if config.TokenMatching {
+		tokenIndex = computeTokenIndex(streetsAndLocations)
+	}
+
+	// compute the n-gram index (if needed)
+	var ngramIndex map[string][]*Place
+	if config.InfixMatching {
+		ngramIndex = computeNgramIndex(streetsAndLocations)
+	}
+
+	// build the spatial index over addresses (i.e. house numbers and locations)
+	addresses := make([]*Place, 0, metrics.HouseNumberCount+metrics.LocationCount)
+	for _, place := range placesMap {
+		if place.Class == HouseNumberClass || place.Class == LocationClass {
+			addresses = append(addresses, place)
+		}
+	}
+	spatialIndex := newKDTree(addresses)
+
 	// initialize cache
 	cache, errCache := ristretto.NewCache(&ristretto.Config{
 		NumCounters: 1e6,     // number of keys to track frequency of (1M).
@@ -123,33 +278,105 @@ func (config Config) NewPlaces(dataProvider Provider) (*Places, error) {
 
 	// basic init
 	//places := Places{config: &config, metrics: &Metrics{}}
-	return &Places{
+	bp := &Places{
 		config:              &config,
 		m:                   sync.RWMutex{},
 		metrics:             metrics,
 		districtsMap:        districtsMap,
+		postcodes:           sortedDistricts(districtsMap),
 		placesMap:           placesMap,
 		streetsAndLocations: streetsAndLocations,
-		prefixCompletions:   prefixCompletions,
+		lexicalOrder:        lexicalOrder,
+		prefixCompletions:   newPrefixTable(prefixCompletions),
+		fuzzyIndex:          computeFuzzyIndex(streetsAndLocations, config.MaxPrefixLength, config.FuzzyDistance),
+		tokenIndex:          tokenIndex,
+		ngramIndex:          ngramIndex,
+		phoneticIndex:       computePhoneticIndex(streetsAndLocations),
+		spatialIndex:        spatialIndex,
 		cache:               cache,
-	}, nil
+		rankings:            newRankings(config.PaginationTTL, maxRankingCount),
+		distance:            distance,
+	}
+	bp.ranker = bp.newRanker()
+
+	// walk names as a trie for early typos (if needed)
+	if config.FuzzyDistance > 0 {
+		bp.fuzzyTrie = computeFuzzyTrie(lexicalOrder, streetsAndLocations)
+	}
+
+	// precomputed completions are in default order with distances in Levenshtein
+	// distance, rerank them for other rankers or metrics (see distanceFuncOf)
+	_, isDefaultRanker := bp.ranker.(defaultRanker)
+	if !isDefaultRanker || (config.DistanceMetric != "" && config.DistanceMetric != LevenshteinMetric) {
+		for prefix, entry := range bp.prefixCompletions {
+			if len([]rune(prefix)) < config.MaxPrefixLength {
+				entry.store(bp.rankedCompletion(bp.prefixPlaces(prefix), prefix))
+			}
+		}
+	}
 
+	return bp, nil
 }
 
+// MatchType enumerates how a result matches the input.
+type MatchType string
+
+const (
+
+	// PrefixMatch is the match type of places whose name starts with the input.
+	PrefixMatch MatchType = "prefix"
+
+	// InfixMatch is the match type of places whose name contains the input (infix matching only).
+	InfixMatch MatchType = "infix"
+
+	// FuzzyMatch is the match type of places matched via edit distance.
+	FuzzyMatch MatchType = "fuzzy"
+
+	// TokenMatch is the match type of places matched token by token.
+	TokenMatch MatchType = "token"
+
+	// PhoneticMatch is the match type of places sounding like the input.
+	PhoneticMatch MatchType = "phonetic"
+
+	// PostcodeMatch is the match type of postcode entries (and of places in a
+	// postcode) for numeric input.
+	PostcodeMatch MatchType = "postcode"
+)
+
 // Result wraps a place.
 type Result struct {
-	Distance int    `json:"distance"`
-	Place    *Place `json:"place"`
-}
+	Distance int       `json:"distance"`
+	Place    *Place    `json:"place,omitempty"`
+	Match    MatchType `json:"match"`
+
+	// District is the district of postcode entries (which have no place, see PostcodeMatch).
+	District *District `json:"district,omitempty"`
+
+	// GeoDistance is the distance (in meters) to the reference point of the query (if any).
+	GeoDistance *float64 `json:"geoDistance,omitempty"`
 
-// completion represents precomputed results and places (for a given prefix)
-type completion struct {
+	// Coverage is the fraction of input tokens matched by the place (token matching only).
+	Coverage float64 `json:"coverage,omitempty"`
+
+	// Spans are the parts (rune offsets) of the place name (or postcode) matching the input (e.g. for highlighting).
+	Spans []Span `json:"spans,omitempty"`
+
+	// Partial is true, if the LatencyBudget was exceeded and thus only prefix matches were considered.
+	Partial bool `json:"partial,omitempty"`
+
+	// Dataset is the name of the dataset of the result (only for searches across datasets, see Registry).
+	Dataset string `json:"dataset,omitempty"`
+
+	// penalty is the proximity penalty (in edit distance) used in ranking.
+	penalty float64
+}
 
-	// results (i.e. places to suggest for this prefix (only if < MaxPrefixLength)
-	results []*Result
+// ReverseResult wraps a place found via reverse geocoding.
+type ReverseResult struct {
 
-	// places covered by this prefix (if < MaxPrefixLength those are the places in the results)
-	places []*Place
+	// Distance is the distance (in meters) between the place and the requested coordinates.
+	Distance float64 `json:"distance"`
+	Place    *Place  `json:"place"`
 }
 
 // Config returns the configuration.
@@ -159,26 +386,94 @@ func (bp *Places) Config() Config {
 
 // Metrics returns current metrics.
 func (bp *Places) Metrics() Metrics {
+	bp.m.RLock()
+	defer bp.m.RUnlock()
 	return *bp.metrics
 }
 
-// GetCompletions returns results for the given input.
-func (bp *Places) GetCompletions(ctx context.Context, input string) []*Result {
+// GetCompletions returns results for the given query. If the query has a Limit
+// or an Offset, the requested page of results is returned. If the given
+// context is canceled, GetCompletions returns no results.
+func (bp *Places) GetCompletions(ctx context.Context, query Query) []*Result {
+	r, _ := bp.GetCompletionsPartial(ctx, query)
+	return r
+}
+
+// GetCompletionsPartial is like GetCompletions, but additionally reports
+// whether the results are partial (i.e. whether the LatencyBudget was exceeded
+// and thus only prefix matches were considered). Other than Result.Partial,
+// this is reported even if there are no results.
+func (bp *Places) GetCompletionsPartial(ctx context.Context, query Query) ([]*Result, bool) {
 	start := time.Now()
-	r := bp.getCompletions(ctx, input)
+
+	// limit the time to spend (if desired)
+	if bp.config.LatencyBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, bp.config.LatencyBudget)
+		defer cancel()
+	}
+
+	var r []*Result
+	if query.paginated() {
+		r = bp.pagedCompletions(ctx, query)
+	} else {
+		r = bp.getCompletions(ctx, query)
+	}
 	go bp.updateMetrics(time.Since(start))
-	return r
+
+	// results are partial, if flagged so or if there are none as the budget was exceeded
+	partial := len(r) == 0 && errors.Is(ctx.Err(), context.DeadlineExceeded)
+	for _, result := range r {
+		partial = partial || result.Partial
+	}
+	return r, partial
 }
 
-func (bp *Places) getCompletions(_ context.Context, input string) []*Result {
+func (bp *Places) getCompletions(ctx context.Context, query Query) []*Result {
+
+	// if the input is (or starts with) a postcode, complete postcodes or restrict the search to the postcode
+	if postcode, text, ok := bp.splitPostcode(query.Text); ok {
+		if text == "" {
+			return bp.postcodeCompletions(postcode, query)
+		}
+		postcodeQuery, satisfiable := query.withPostcode(postcode)
+		if !satisfiable {
+			return []*Result{}
+		}
+		query = postcodeQuery
+		query.Text = text
+	}
+
+	// if the input contains a house number, complete house numbers
+	address := parseAddress(query.Text, bp.isNamePrefix)
+	if address.HouseNumber != "" {
+		return bp.houseNumberCompletions(ctx, address, query)
+	}
+
+	// if the input contains a postcode following the street part, prefer places in that postcode
+	if address.Postcode != "" && address.Street != "" {
+		return bp.postcodeAddressCompletions(ctx, address, query)
+	}
 
 	// dissect the input
-	simpleInput := SanitizeString(input)
+	simpleInput := SanitizeString(query.Text)
 	runes := []rune(simpleInput)
 	inputLength := len(runes)
 
-	// if we have a matching cache entry return it
-	cacheResults, hit := bp.cache.Get(simpleInput)
+	// compute input tokens (if needed)
+	tokens := bp.queryTokens(query.Text)
+
+	// refined queries can neither use cached nor precomputed results
+	if query.refined() {
+		return bp.refinedCompletions(ctx, simpleInput, tokens, query)
+	}
+
+	// if we have a matching cache entry return it (with token matching, results depend on the tokens)
+	cacheKey := simpleInput
+	if tokens != nil {
+		cacheKey = strings.Join(tokens, " ")
+	}
+	cacheResults, hit := bp.cacheGet(cacheKey)
 	if hit {
 		if results, ok := cacheResults.([]*Result); ok {
 
@@ -198,10 +493,13 @@ func (bp *Places) getCompletions(_ context.Context, input string) []*Result {
 		prefixString := string(runes[:Min(len(runes), bp.config.MaxPrefixLength)])
 
 		// if we have a matching entry in the prefix map
-		if pf, ok := bp.prefixCompletions[prefixString]; ok {
+		if pf, ok := bp.prefixCompletion(prefixString); ok {
 
 			// do Levenshtein on the places associated with this prefix
-			results := bp.levenshtein(pf.places, simpleInput)
+			results, err := bp.levenshtein(ctx, pf.places, nil, simpleInput, tokens)
+			if err != nil {
+				return bp.partialCompletions(ctx, simpleInput, query)
+			}
 
 			go func() {
 
@@ -209,15 +507,19 @@ func (bp *Places) getCompletions(_ context.Context, input string) []*Result {
 				bp.updateRelevance(results, simpleInput)
 
 				// try to cache results (i.e. we extend the prefix map by longer prefixes)
-				bp.cache.SetWithTTL(simpleInput, results, 0, bp.config.CacheTTL)
+				bp.cacheSet(cacheKey, results)
 			}()
 
 			return results
 
 		} else {
 
-			// do Levenshtein on all streets and locations
-			results := bp.levenshtein(bp.streetsAndLocations, simpleInput)
+			// do Levenshtein on streets and locations with similar prefixes (or all of them)
+			candidates, distances := bp.fuzzyCandidates(simpleInput, tokens)
+			results, err := bp.levenshtein(ctx, candidates, distances, simpleInput, tokens)
+			if err != nil {
+				return bp.partialCompletions(ctx, simpleInput, query)
+			}
 
 			go func() {
 
@@ -225,7 +527,7 @@ func (bp *Places) getCompletions(_ context.Context, input string) []*Result {
 				bp.updateRelevance(results, simpleInput)
 
 				// try to cache results (i.e. we extend the prefix map by long "faulty" prefixes)
-				bp.cache.SetWithTTL(simpleInput, results, 0, bp.config.CacheTTL)
+				bp.cacheSet(cacheKey, results)
 			}()
 
 			return results
@@ -235,7 +537,7 @@ func (bp *Places) getCompletions(_ context.Context, input string) []*Result {
 	// simpleInput length is smaller than max prefix length thus the simpleInput is the prefix to match
 
 	// if we have a matching entry in the prefixCompletions, then return the results for that
-	if pf, ok := bp.prefixCompletions[simpleInput]; ok {
+	if pf, ok := bp.prefixCompletion(simpleInput); ok {
 
 		// update relevance
 		go bp.updateRelevance(pf.results, simpleInput)
@@ -246,8 +548,12 @@ func (bp *Places) getCompletions(_ context.Context, input string) []*Result {
 	// there is no matching prefix, but above MinLev
 	if inputLength >= bp.config.MinLev {
 
-		// do levenshtein on all streets and location
-		results := bp.levenshtein(bp.streetsAndLocations, simpleInput)
+		// do levenshtein on streets and locations with similar prefixes (or all of them)
+		candidates, distances := bp.fuzzyCandidates(simpleInput, tokens)
+		results, err := bp.levenshtein(ctx, candidates, distances, simpleInput, tokens)
+		if err != nil {
+			return bp.partialCompletions(ctx, simpleInput, query)
+		}
 
 		go func() {
 
@@ -255,7 +561,7 @@ func (bp *Places) getCompletions(_ context.Context, input string) []*Result {
 			bp.updateRelevance(results, simpleInput)
 
 			// try to cache results
-			bp.cache.SetWithTTL(simpleInput, results, 0, bp.config.CacheTTL)
+			bp.cacheSet(cacheKey, results)
 		}()
 
 		return results
@@ -265,6 +571,38 @@ func (bp *Places) getCompletions(_ context.Context, input string) []*Result {
 	return []*Result{}
 }
 
+// Close releases the cache of Places (e.g. once replaced by a reload). It waits
+// for pending cache lookups and updates, Places closed keep serving requests
+// (without caching).
+func (bp *Places) Close() {
+	bp.cacheMutex.Lock()
+	defer bp.cacheMutex.Unlock()
+	if bp.cache != nil {
+		bp.cache.Close()
+		bp.cache = nil
+	}
+}
+
+// cacheGet returns the cached results for the given key (if any).
+func (bp *Places) cacheGet(key string) (interface{}, bool) {
+	bp.cacheMutex.RLock()
+	defer bp.cacheMutex.RUnlock()
+	if bp.cache == nil {
+		return nil, false
+	}
+	return bp.cache.Get(key)
+}
+
+// cacheSet tries to cache the given results (unless closed).
+func (bp *Places) cacheSet(key string, results []*Result) {
+	bp.cacheMutex.RLock()
+	defer bp.cacheMutex.RUnlock()
+	if bp.cache == nil {
+		return
+	}
+	bp.cache.SetWithTTL(key, results, 0, bp.config.CacheTTL)
+}
+
 func (bp *Places) GetPlace(ctx context.Context, placeID int64, houseNumber string) *Place {
 	start := time.Now()
 	p := bp.getPlace(ctx, placeID, houseNumber)
@@ -278,11 +616,24 @@ func (bp *Places) getPlace(_ context.Context, placeID int64, houseNumber string)
 			return p
 		} else {
 			if p.Class == StreetClass && p.HouseNumbers != nil {
+
+				// prefer equal house numbers over ranges containing the house number
+				normalized := NormalizeHouseNumber(houseNumber)
 				for _, house := range p.HouseNumbers {
-					if house.HouseNumber == houseNumber {
+					if house.HouseNumber == normalized {
 						return house
 					}
 				}
+				if parsed, err := ParseHouseNumber(houseNumber); err == nil {
+					for _, house := range p.HouseNumbers {
+						if house.houseNumber.Contains(parsed) {
+							return house
+						}
+					}
+
+					// the house number is missing, estimate its position (if possible)
+					return interpolateHouseNumber(p, parsed)
+				}
 			}
 		}
 	}
@@ -290,6 +641,28 @@ func (bp *Places) getPlace(_ context.Context, placeID int64, houseNumber string)
 	return nil
 }
 
+// Reverse returns the k addresses (i.e. house numbers and locations) closest to
+// the given coordinates (closest first). Streets and districts are available via
+// the street (and district) of the addresses.
+func (bp *Places) Reverse(ctx context.Context, lat, lon float64, k int) []*ReverseResult {
+	start := time.Now()
+	r := bp.reverse(ctx, lat, lon, k)
+	go bp.updateMetrics(time.Since(start))
+	return r
+}
+
+func (bp *Places) reverse(_ context.Context, lat, lon float64, k int) []*ReverseResult {
+	points := bp.spatialIndex.nearest(lat, lon, k)
+	results := make([]*ReverseResult, len(points))
+	for i, point := range points {
+		results[i] = &ReverseResult{
+			Distance: Haversine(lat, lon, point.place.Lat, point.place.Lon),
+			Place:    point.place,
+		}
+	}
+	return results
+}
+
 // computePrefixCompletions compute completions for prefixes of street- and location names.
 func computePrefixCompletions(streetsAndLocations []*Place, maxPrefixLength, minCompletionCount int) map[string]*completion {
 
@@ -317,6 +690,8 @@ func computePrefixCompletions(streetsAndLocations []*Place, maxPrefixLength, min
 					r := Result{
 						Distance: remainderLength,
 						Place:    p,
+						Match:    PrefixMatch,
+						Spans:    toSpans(p, positionRange(0, prefixLen)),
 					}
 					pc[prefixStr].places = append(pc[prefixStr].places, p)
 					pc[prefixStr].results = append(pc[prefixStr].results, &r)
@@ -346,6 +721,9 @@ func (bp *Places) updateRelevance(results []*Result, simpleInput string) []*Plac
 
 			// increase relevance (thread safe)
 			atomic.AddUint64(&r.Place.Relevance, 1)
+			if bp.config.RelevanceHalfLife > 0 {
+				r.Place.addScore(time.Now(), bp.config.RelevanceHalfLife)
+			}
 
 			updatedPlaces = append(updatedPlaces, r.Place)
 		}
@@ -374,54 +752,120 @@ func (bp *Places) updateCompletions(updatedPlaces []*Place) {
 		}
 	}
 
+	// updates are read-modify-write, thus concurrent updates must not interleave
+	bp.updateMutex.Lock()
+	defer bp.updateMutex.Unlock()
+
 	for d := 1; d < Min(bp.config.MaxPrefixLength, runesLen); d++ {
 
 		prefixStr := string(runes[:d])
 
 		// get the current results for this prefix
-		currentPlaces := bp.prefixCompletions[prefixStr].places
+		entry, ok := bp.prefixCompletions[prefixStr]
+		if !ok {
+			continue
+		}
+		currentPlaces := entry.load().places
 
-		// merge the results that where updated to the current results and deduplicate
-		mergedPlaces := deDuplicate(append(currentPlaces, updatedPlaces...))
+		// merge the results that where updated to the current results and deduplicate (on a
+		// copy, as readers may still use the current places)
+		mergedPlaces := make([]*Place, 0, len(currentPlaces)+len(updatedPlaces))
+		mergedPlaces = append(mergedPlaces, currentPlaces...)
+		mergedPlaces = deDuplicate(append(mergedPlaces, updatedPlaces...))
 
-		// do Levenshtein on the merged places wrt. the prefix string
-		results := bp.levenshtein(mergedPlaces, prefixStr)
+		// publish the new completion (readers get either the old or the new one)
+		entry.store(bp.rankedCompletion(mergedPlaces, prefixStr))
 
-		var newCompletions []*Result
-		var newPlaces []*Place
-		for _, r := range results {
-			if r.Place.SimpleName == prefixStr || len(newCompletions) < bp.config.MinCompletionCount {
-				newCompletions = append(newCompletions, r)
-				newPlaces = append(newPlaces, r.Place)
-			}
-		}
+	}
+}
+
+// rankedCompletion computes the completion for the given prefix from the given
+// places. That is, the top ranked places (see levenshtein) plus exact matches.
+func (bp *Places) rankedCompletion(places []*Place, prefixStr string) *completion {
 
-		bp.prefixCompletions[prefixStr].places = newPlaces
-		bp.prefixCompletions[prefixStr].results = newCompletions
+	// do Levenshtein on the places wrt. the prefix string
+	results, _ := bp.levenshtein(context.Background(), places, nil, prefixStr, nil)
 
+	var newCompletions []*Result
+	var newPlaces []*Place
+	for _, r := range results {
+		if r.Place.SimpleName == prefixStr || len(newCompletions) < bp.config.MinCompletionCount {
+			newCompletions = append(newCompletions, r)
+			newPlaces = append(newPlaces, r.Place)
+		}
 	}
+	return &completion{results: newCompletions, places: newPlaces}
 }
 
-func (bp *Places) levenshtein(places []*Place, simpleInput string) []*Result {
+// levenshtein computes, ranks and truncates results for the given places (see
+// computeResults). If the given context is done before, levenshtein returns the
+// context's error.
+func (bp *Places) levenshtein(ctx context.Context, places []*Place, distances map[*Place]int, simpleInput string, tokens []string) ([]*Result, error) {
+	places = bp.withInfixCandidates(bp.withTokenCandidates(places, tokens), simpleInput)
+	results, err := bp.computeResults(ctx, places, distances, simpleInput, tokens)
+	if err != nil {
+		return nil, err
+	}
+	results = bp.withPhoneticResults(results, simpleInput, Query{})
+	return withSpans(bp.distance, bp.truncate(bp.sortResults(results), simpleInput), simpleInput, tokens), nil
+}
 
-	// for each place compute the Levenshtein-Distance between its simple name and the given simple input
+// computeResults computes the Levenshtein-Distance between the simple name of
+// each place and the given simple input (unless given as known distance). If tokens are given, results also
+// carry their token coverage and (for full coverage) the distance is the
+// smaller of both the Levenshtein- and the token distance. If the given context
+// is done before, computeResults returns the context's error.
+func (bp *Places) computeResults(ctx context.Context, places []*Place, distances map[*Place]int, simpleInput string, tokens []string) ([]*Result, error) {
 	results := make([]*Result, len(places))
 	for i, p := range places {
+
+		// check for cancellation (every now and then)
+		if i%cancellationInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		distance, ok := distances[p]
+		if !ok {
+			distance = bp.distance(simpleInput, p.SimpleName)
+		}
 		results[i] = &Result{
-			Distance: levenshtein.ComputeDistance(simpleInput, p.SimpleName),
+			Distance: distance,
 			Place:    p,
+			Match:    FuzzyMatch,
+		}
+		if strings.HasPrefix(p.SimpleName, simpleInput) {
+			results[i].Match = PrefixMatch
+		} else if bp.config.InfixMatching && strings.Contains(p.SimpleName, simpleInput) {
+			results[i].Match = InfixMatch
+		}
+		if len(tokens) > 0 {
+			coverage, tokenDistance := tokenMatch(bp.distance, tokens, p)
+			results[i].Coverage = coverage
+			if coverage == 1 && tokenDistance < results[i].Distance {
+				results[i].Distance = tokenDistance
+				results[i].Match = TokenMatch
+			}
 		}
 	}
+	return results, nil
+}
 
-	// sort results via place ranking.
+// sortResults sorts results via the ranker.
+func (bp *Places) sortResults(results []*Result) []*Result {
+	ranker := bp.rankerAt(time.Now())
 	sort.Slice(results, func(i, j int) bool {
-		return bp.resultRanking(results[i], results[j])
+		return ranker.Less(results[i], results[j])
 	})
+	return results
+}
+
+// truncate returns the first MinCompletionCount results (plus subsequent exact matches, if any).
+func (bp *Places) truncate(results []*Result, simpleInput string) []*Result {
 
 	// compute the number of results to return (i.e. all exact matches filled up to MinCompletionCount)
 	count := Min(bp.config.MinCompletionCount, len(results))
 	for i := count; i < len(results); i++ {
-		if results[i].Place.SimpleName == simpleInput {
+		if results[i].exactMatch(simpleInput) {
 
 			// we are past MinCompletionCount but still have an exact match, therefore add it
 			count += 1
@@ -436,6 +880,15 @@ func (bp *Places) levenshtein(places []*Place, simpleInput string) []*Result {
 	return results[:count]
 }
 
+// exactMatch returns true, if the result exactly matches the given (simplified) input (i.e. the name of its place
+// or, for postcode entries without a place, its postcode equals the input).
+func (r *Result) exactMatch(simpleInput string) bool {
+	if r.Place == nil {
+		return r.District != nil && r.District.Postcode == simpleInput
+	}
+	return r.Place.SimpleName == simpleInput
+}
+
 func (bp *Places) updateMetrics(duration time.Duration) {
 	bp.m.Lock()
 	defer bp.m.Unlock()
@@ -447,11 +900,20 @@ func (bp *Places) updateMetrics(duration time.Duration) {
 	}
 }
 
-// resultRanking compares two levenshtein results wrt. distance, relevance, class, and (in case of streets) length.
+// resultRanking (i.e. the default ranking) compares two levenshtein results wrt. distance, relevance, class, and (in case of streets) length.
 // resultRanking returns true, if the first place should be ranked higher than the second one. resultRanking should
 // be used in sorting slices (analog to the lesser function) sorting higher ranks to the beginning.
 func (bp *Places) resultRanking(i, j *Result) bool {
 
+	// If token coverages differ, rank the result covering more input tokens higher.
+	if i.Coverage != j.Coverage {
+		if i.Coverage > j.Coverage {
+			return true
+		} else {
+			return false
+		}
+	}
+
 	di := i.Distance
 	dj := j.Distance
 
@@ -465,11 +927,28 @@ func (bp *Places) resultRanking(i, j *Result) bool {
 		}
 	}
 
+	// If infix matching is enabled, rank prefix matches over infix matches over other matches.
+	if bp.config.InfixMatching {
+		ti := matchTier(i)
+		tj := matchTier(j)
+		if ti != tj {
+			if ti < tj {
+				return true
+			} else {
+				return false
+			}
+		}
+	}
+
+	// Beyond exact matches, distances include the proximity penalty (if any).
+	ei := float64(di) + i.penalty
+	ej := float64(dj) + j.penalty
+
 	// If none of the results is an exact match but the delta in distances is greater than DistanceCut
 	// the one with the smaller distance will be ranked higher.
-	distanceDelta := Abs(di - dj)
-	if distanceDelta > bp.config.DistanceCut {
-		if di < dj {
+	distanceDelta := math.Abs(ei - ej)
+	if distanceDelta > float64(bp.config.DistanceCut) {
+		if ei < ej {
 			return true
 		} else {
 			return false
@@ -480,9 +959,11 @@ func (bp *Places) resultRanking(i, j *Result) bool {
 	pj := j.Place
 
 	// As there is no exact match and the delta in distances is within DistanceCut,
-	// rank by relevance (if different).
-	if pi.Relevance != pj.Relevance {
-		if pi.Relevance > pj.Relevance {
+	// rank by (decayed) relevance (if different).
+	ri := bp.relevanceOf(pi)
+	rj := bp.relevanceOf(pj)
+	if ri != rj {
+		if ri > rj {
 			return true
 		} else {
 			return false
@@ -490,16 +971,31 @@ func (bp *Places) resultRanking(i, j *Result) bool {
 	}
 
 	// relevance is equal, come back to distances
-	if di != dj {
-		if di < dj {
+	if ei != ej {
+		if ei < ej {
 			return true
 		} else {
 			return false
 		}
 	}
 
-	// results have same distance and relevance, rank streets over locations
+	// distances are equal, rank the place closer to the reference point higher (if any)
+	if i.GeoDistance != nil && j.GeoDistance != nil && *i.GeoDistance != *j.GeoDistance {
+		if *i.GeoDistance < *j.GeoDistance {
+			return true
+		} else {
+			return false
+		}
+	}
+
+	// results have same distance and relevance, rank districts over streets over locations
 	if pi.Class != pj.Class {
+		if pi.Class == DistrictClass {
+			return true
+		}
+		if pj.Class == DistrictClass {
+			return false
+		}
 		if pi.Class == StreetClass {
 			return true
 		} else {
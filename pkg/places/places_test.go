@@ -5,8 +5,15 @@ import (
 	"fmt"
 	"github.com/heimdalr/berlinplaces/pkg/data"
 	"github.com/heimdalr/berlinplaces/pkg/places"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 const (
@@ -20,8 +27,30 @@ id,type,name,street_id,house_number,postcode,length,lat,lon
 1,,Elisabeth-Feller-Weg,,,12524,10,52.51121427531362,13.433862108201659
 2,,Aachener Straße,,,10961,100,52.48010401206288,13.318894891444728
 3,,Aalemannufer,,,10961,1000,52.57313191552375,13.218142687594606
+4,,Hauptstraße,,,10961,500,52.49,13.35
+5,,Hauptstraße,,,12524,800,52.45,13.62
+6,,Platz der Luftbrücke,,,10961,300,52.4849,13.3862
+7,,Köpenicker Straße,,,10961,1500,52.5074,13.4277
+8,,Köppelweg,,,12524,200,52.4321,13.5821
+9,,Straße des 17. Juni,,,10961,3500,52.5139,13.3502
 4294967297,restaurant,Strandlust,1,3a,12524,,52.3762307,13.657224
 8589934593,,,1,1,12524,,52.4127212,13.5714066
+8589934594,,,1,10,12524,,52.4128,13.5715
+8589934595,,,1,12a,12524,,52.4129,13.5716
+`
+
+	// NumberedPlacesCSV are (real) Berlin streets with numbers in their names.
+	NumberedPlacesCSV = `
+id,type,name,street_id,house_number,postcode,length,lat,lon
+1,,Straße des 17. Juni,,,10961,3500,52.5139,13.3502
+2,,Platz des 9. November 1989,,,10961,150,52.5549,13.3982
+3,,Straße 70,,,12524,300,52.6004,13.4172
+4,,Straße 645,,,12524,400,52.6051,13.3953
+5,,Allee der Kosmonauten,,,12524,4000,52.5397,13.5510
+8589934593,,,1,135,10961,,52.5125,13.3269
+8589934594,,,3,12,12524,,52.6006,13.4175
+8589934595,,,5,2a,12524,,52.5384,13.5221
+8589934596,,,5,20,12524,,52.5389,13.5248
 `
 )
 
@@ -65,7 +94,7 @@ func TestPlaces_GetCompletions(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := p.GetCompletions(context.Background(), tt.text)
+			r := p.GetCompletions(context.Background(), places.Query{Text: tt.text})
 			rLength := len(r)
 			if rLength < 1 {
 				t.Errorf("got %d, want > 0", rLength)
@@ -77,3 +106,1784 @@ func TestPlaces_GetCompletions(t *testing.T) {
 		})
 	}
 }
+
+func TestPlaces_Reverse(t *testing.T) {
+
+	dataProvider := data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	}
+
+	p, err := places.DefaultConfig.NewPlaces(dataProvider)
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		name      string
+		lat       float64
+		lon       float64
+		k         int
+		wantCount int
+		wantID    int64
+	}{
+		{
+			name:      "house number",
+			lat:       52.4127,
+			lon:       13.5714,
+			k:         1,
+			wantCount: 1,
+			wantID:    8589934593,
+		},
+		{
+			name:      "location",
+			lat:       52.3762307,
+			lon:       13.657224,
+			k:         3,
+			wantCount: 3,
+			wantID:    4294967297,
+		},
+		{
+			// the closest point is the street Köppelweg (52.4321,13.5821), but streets aren't addresses
+			name:      "address over street",
+			lat:       52.4320,
+			lon:       13.5820,
+			k:         1,
+			wantCount: 1,
+			wantID:    8589934595,
+		},
+		{
+			name:      "more than available",
+			lat:       52.48,
+			lon:       13.31,
+			k:         20,
+			wantCount: 4,
+			wantID:    8589934593,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := p.Reverse(context.Background(), tt.lat, tt.lon, tt.k)
+			if len(r) != tt.wantCount {
+				t.Fatalf("got %d, want %d", len(r), tt.wantCount)
+			}
+			if r[0].Place.ID != tt.wantID {
+				t.Errorf("got %d, want %d", r[0].Place.ID, tt.wantID)
+			}
+			for _, result := range r {
+				if result.Place.Class != places.HouseNumberClass && result.Place.Class != places.LocationClass {
+					t.Errorf("got %s, want addresses only", result.Place.Class)
+				}
+			}
+			for i := 1; i < len(r); i++ {
+				if r[i].Distance < r[i-1].Distance {
+					t.Errorf("results not ordered by distance")
+				}
+			}
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsNear(t *testing.T) {
+
+	dataProvider := data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	}
+
+	p, err := places.DefaultConfig.NewPlaces(dataProvider)
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		name   string
+		text   string
+		near   places.Point
+		wantID int64
+	}{
+		{
+			name:   "Hauptstraße (Schöneberg)",
+			text:   "Hauptstraße",
+			near:   places.Point{Lat: 52.48, Lon: 13.34},
+			wantID: 4,
+		},
+		{
+			name:   "Hauptstraße (Köpenick)",
+			text:   "Hauptstraße",
+			near:   places.Point{Lat: 52.44, Lon: 13.61},
+			wantID: 5,
+		},
+		{
+			name:   "Haupt (Köpenick)",
+			text:   "Haupt",
+			near:   places.Point{Lat: 52.44, Lon: 13.61},
+			wantID: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			near := tt.near
+			r := p.GetCompletions(context.Background(), places.Query{Text: tt.text, Near: &near})
+			if len(r) < 2 {
+				t.Fatalf("got %d, want > 1", len(r))
+			}
+			if r[0].Place.ID != tt.wantID {
+				t.Errorf("got %d, want %d", r[0].Place.ID, tt.wantID)
+			}
+			if r[0].GeoDistance == nil || r[1].GeoDistance == nil || *r[0].GeoDistance > *r[1].GeoDistance {
+				t.Errorf("missing or unordered geo distances")
+			}
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsFiltered(t *testing.T) {
+
+	dataProvider := data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	}
+
+	p, err := places.DefaultConfig.NewPlaces(dataProvider)
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		name      string
+		query     places.Query
+		wantCount int
+		wantID    int64
+	}{
+		{
+			name:      "postcode",
+			query:     places.Query{Text: "Hauptstraße", Postcodes: []string{"12524"}},
+			wantCount: 1,
+			wantID:    5,
+		},
+		{
+			name:      "district",
+			query:     places.Query{Text: "Haupt", Districts: []string{"Friedrichshain-Kreuzberg"}},
+			wantCount: 1,
+			wantID:    4,
+		},
+		{
+			name:      "class (short prefix)",
+			query:     places.Query{Text: "S", Classes: []places.Class{places.LocationClass}},
+			wantCount: 1,
+			wantID:    4294967297,
+		},
+		{
+			name:      "type",
+			query:     places.Query{Text: "Strandlus", Types: []string{"cafe", "restaurant"}},
+			wantCount: 1,
+			wantID:    4294967297,
+		},
+		{
+			name:      "class (no prefix match)",
+			query:     places.Query{Text: "Elisabeth-Feller-Weg", Classes: []places.Class{places.LocationClass}},
+			wantCount: 1,
+			wantID:    4294967297,
+		},
+		{
+			name:      "no match",
+			query:     places.Query{Text: "Aa", Types: []string{"hotel"}},
+			wantCount: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := p.GetCompletions(context.Background(), tt.query)
+			if len(r) != tt.wantCount {
+				t.Fatalf("got %d, want %d", len(r), tt.wantCount)
+			}
+			if tt.wantCount > 0 && r[0].Place.ID != tt.wantID {
+				t.Errorf("got %d, want %d", r[0].Place.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		input string
+		want  places.Address
+	}{
+		{
+			input: "Oranienstraße",
+			want:  places.Address{Street: "Oranienstraße"},
+		},
+		{
+			input: "Oranienstraße 12 10999",
+			want:  places.Address{Street: "Oranienstraße", HouseNumber: "12", Postcode: "10999"},
+		},
+		{
+			input: "Oranienstraße 12 A, 10999 Berlin",
+			want:  places.Address{Street: "Oranienstraße", HouseNumber: "12a", Postcode: "10999"},
+		},
+		{
+			input: "10999 Elisabeth-Feller-Weg 3a",
+			want:  places.Address{Street: "Elisabeth-Feller-Weg", HouseNumber: "3a", Postcode: "10999"},
+		},
+		{
+			input: "12",
+			want:  places.Address{Street: "12"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := places.ParseAddress(tt.input)
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsAddress(t *testing.T) {
+
+	dataProvider := data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	}
+
+	p, err := places.DefaultConfig.NewPlaces(dataProvider)
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		name      string
+		text      string
+		wantCount int
+		want      string
+	}{
+		{
+			name:      "exact",
+			text:      "Elisabeth-Feller-Weg 1",
+			wantCount: 1,
+			want:      "1",
+		},
+		{
+			name:      "exact with postcode",
+			text:      "Elisabeth-Feller-Weg 1, 12524 Berlin",
+			wantCount: 1,
+			want:      "1",
+		},
+		{
+			name:      "letter suffix",
+			text:      "Elisabeth-Feller-Weg 12 A",
+			wantCount: 1,
+			want:      "12a",
+		},
+		{
+			name:      "completion",
+			text:      "Elisabeth-Feller-Weg 12",
+			wantCount: 1,
+			want:      "12a",
+		},
+		{
+			name:      "typo",
+			text:      "Elisabeth-Felle-Weg 10",
+			wantCount: 1,
+			want:      "10",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := p.GetCompletions(context.Background(), places.Query{Text: tt.text})
+			if len(r) != tt.wantCount {
+				t.Fatalf("got %d, want %d", len(r), tt.wantCount)
+			}
+			if r[0].Place.Class != places.HouseNumberClass || r[0].Place.HouseNumber != tt.want {
+				t.Errorf("got %s, want %s", r[0].Place.HouseNumber, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsTokens(t *testing.T) {
+
+	dataProvider := data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	}
+
+	config := *places.DefaultConfig
+	config.TokenMatching = true
+	p, err := config.NewPlaces(dataProvider)
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		name         string
+		text         string
+		want         string
+		wantDistance int
+	}{
+		{
+			name:         "Elisabeth-Feller-Weg (Exact)",
+			text:         "Elisabeth-Feller-Weg",
+			want:         "Elisabeth-Feller-Weg",
+			wantDistance: 0,
+		},
+		{
+			name:         "Feller Weg Elisabeth (Order)",
+			text:         "Feller Weg Elisabeth",
+			want:         "Elisabeth-Feller-Weg",
+			wantDistance: 0,
+		},
+		{
+			name:         "luftbrücke platz (Order)",
+			text:         "luftbrücke platz",
+			want:         "Platz der Luftbrücke",
+			wantDistance: 3,
+		},
+		{
+			name:         "luftbrüke platz (Order and Typo)",
+			text:         "luftbrüke platz",
+			want:         "Platz der Luftbrücke",
+			wantDistance: 4,
+		},
+		{
+			name:         "weg feller elisab (Order and Prefix)",
+			text:         "weg feller elisab",
+			want:         "Elisabeth-Feller-Weg",
+			wantDistance: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := p.GetCompletions(context.Background(), places.Query{Text: tt.text})
+			if len(r) < 1 {
+				t.Fatalf("got %d, want > 0", len(r))
+			}
+			if r[0].Place.Name != tt.want {
+				t.Errorf("got %s, want %s", r[0].Place.Name, tt.want)
+			}
+			if r[0].Distance != tt.wantDistance {
+				t.Errorf("got distance %d, want %d", r[0].Distance, tt.wantDistance)
+			}
+			if r[0].Coverage != 1 {
+				t.Errorf("got coverage %f, want 1", r[0].Coverage)
+			}
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsAddressFallback(t *testing.T) {
+
+	dataProvider := data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	}
+
+	p, err := places.DefaultConfig.NewPlaces(dataProvider)
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		name      string
+		text      string
+		wantID    int64
+		wantClass places.Class
+	}{
+		{
+			name:      "unknown house number",
+			text:      "Elisabeth-Feller-Weg 13",
+			wantID:    1,
+			wantClass: places.StreetClass,
+		},
+		{
+			name:      "unknown house number with postcode",
+			text:      "Hauptstraße 99, 10961",
+			wantID:    4,
+			wantClass: places.StreetClass,
+		},
+		{
+			name:      "postcode",
+			text:      "Hauptstraße 12524",
+			wantID:    5,
+			wantClass: places.StreetClass,
+		},
+		{
+			name:      "other postcode",
+			text:      "Hauptstraße 10961",
+			wantID:    4,
+			wantClass: places.StreetClass,
+		},
+		{
+			name:      "unknown postcode",
+			text:      "Aachener Straße 12524",
+			wantID:    2,
+			wantClass: places.StreetClass,
+		},
+		{
+			name:      "location",
+			text:      "Elisabeth-Feller-Weg 3a",
+			wantID:    4294967297,
+			wantClass: places.LocationClass,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := p.GetCompletions(context.Background(), places.Query{Text: tt.text})
+			if len(r) < 1 {
+				t.Fatalf("got %d, want > 0", len(r))
+			}
+			if r[0].Place.ID != tt.wantID || r[0].Place.Class != tt.wantClass {
+				t.Errorf("got %s (%d, %s), want %d (%s)", r[0].Place.Name, r[0].Place.ID, r[0].Place.Class, tt.wantID, tt.wantClass)
+			}
+			if r[0].Distance != 0 {
+				t.Errorf("got distance %d, want 0", r[0].Distance)
+			}
+		})
+	}
+}
+
+func TestColognePhonetic(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "Wikipedia", want: "3412"},
+		{input: "Müller-Lüdenscheidt", want: "65752682"},
+		{input: "Breschnew", want: "17863"},
+		{input: "Meier", want: "67"},
+		{input: "Mayer", want: "67"},
+		{input: "Köppenicker", want: "41647"},
+		{input: "Köpenicker", want: "41647"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := places.ColognePhonetic(tt.input); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsPhonetic(t *testing.T) {
+
+	p, err := places.DefaultConfig.NewPlaces(data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	// places without the district Köpenick and the street Köppelweg (both closer to "Köppenicker")
+	kreuzberg, err := places.DefaultConfig.NewPlaces(data.CSVProvider{
+		DistrictsReader: strings.NewReader(`
+postcode,district
+10961,Friedrichshain-Kreuzberg
+`),
+		PlacesReader: strings.NewReader(`
+id,type,name,street_id,house_number,postcode,length,lat,lon
+2,,Aachener Straße,,,10961,100,52.48010401206288,13.318894891444728
+3,,Aalemannufer,,,10961,1000,52.57313191552375,13.218142687594606
+7,,Köpenicker Straße,,,10961,1500,52.5074,13.4277
+`),
+	})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		name      string
+		places    *places.Places
+		text      string
+		want      string
+		wantMatch places.MatchType
+		wantFirst bool
+	}{
+		{
+			name:      "Köpenicker Str (Prefix)",
+			places:    p,
+			text:      "Köpenicker Str",
+			want:      "Köpenicker Straße",
+			wantMatch: places.PrefixMatch,
+			wantFirst: true,
+		},
+		{
+			name:      "Köppenicker (Phonetic)",
+			places:    kreuzberg,
+			text:      "Köppenicker",
+			want:      "Köpenicker Straße",
+			wantMatch: places.PhoneticMatch,
+			wantFirst: true,
+		},
+		{
+			name:      "Köppenicker (District)",
+			places:    p,
+			text:      "Köppenicker",
+			want:      "Köpenick",
+			wantMatch: places.FuzzyMatch,
+			wantFirst: true,
+		},
+		{
+			// the district Köpenick is closer, thus ranked first
+			name:      "Köppenicker (Phonetic next to District)",
+			places:    p,
+			text:      "Köppenicker",
+			want:      "Köpenicker Straße",
+			wantMatch: places.PhoneticMatch,
+		},
+		{
+			name:      "Köppenicker Str (Phonetic)",
+			places:    p,
+			text:      "Köppenicker Str",
+			want:      "Köpenicker Straße",
+			wantMatch: places.PhoneticMatch,
+			wantFirst: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.places.GetCompletions(context.Background(), places.Query{Text: tt.text})
+			if len(r) < 1 {
+				t.Fatalf("got %d, want > 0", len(r))
+			}
+			if tt.wantFirst && r[0].Place.Name != tt.want {
+				t.Errorf("got %s, want %s", r[0].Place.Name, tt.want)
+			}
+			for _, result := range r {
+				if result.Place.Name == tt.want {
+					if result.Match != tt.wantMatch {
+						t.Errorf("got %s, want %s", result.Match, tt.wantMatch)
+					}
+					return
+				}
+			}
+			t.Errorf("got %v, want %s among results", r, tt.want)
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsInfix(t *testing.T) {
+
+	dataProvider := data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	}
+
+	config := *places.DefaultConfig
+	config.InfixMatching = true
+	p, err := config.NewPlaces(dataProvider)
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		name      string
+		text      string
+		want      []string
+		wantMatch []places.MatchType
+	}{
+		{
+			name:      "luftbrücke (Infix)",
+			text:      "luftbrücke",
+			want:      []string{"Platz der Luftbrücke"},
+			wantMatch: []places.MatchType{places.InfixMatch},
+		},
+		{
+			name:      "penicker (Infix)",
+			text:      "penicker",
+			want:      []string{"Köpenicker Straße"},
+			wantMatch: []places.MatchType{places.InfixMatch},
+		},
+		{
+			name:      "straße (Prefix over Infix)",
+			text:      "straße",
+			want:      []string{"Straße des 17. Juni", "Hauptstraße"},
+			wantMatch: []places.MatchType{places.PrefixMatch, places.InfixMatch},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := p.GetCompletions(context.Background(), places.Query{Text: tt.text})
+			if len(r) < len(tt.want) {
+				t.Fatalf("got %d, want >= %d", len(r), len(tt.want))
+			}
+			for i := range tt.want {
+				if r[i].Place.Name != tt.want[i] {
+					t.Errorf("%d: got %s, want %s", i, r[i].Place.Name, tt.want[i])
+				}
+				if r[i].Match != tt.wantMatch[i] {
+					t.Errorf("%d: got %s, want %s", i, r[i].Match, tt.wantMatch[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsPaginated(t *testing.T) {
+
+	p, err := places.DefaultConfig.NewPlaces(syntheticProvider{})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	// the first page
+	first := p.GetCompletions(context.Background(), places.Query{Text: "Oranien", Limit: 20})
+	if len(first) != 20 {
+		t.Fatalf("got %d, want 20", len(first))
+	}
+
+	// make the last result of the first page highly relevant (which would move it to the top)
+	for i := 0; i < 10; i++ {
+		p.GetCompletions(context.Background(), places.Query{Text: first[19].Place.Name})
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// subsequent pages are cut from the same ranking
+	second := p.GetCompletions(context.Background(), places.Query{Text: "Oranien", Limit: 10, Offset: 10})
+	if len(second) != 10 {
+		t.Fatalf("got %d, want 10", len(second))
+	}
+	for i, r := range second {
+		if r.Place.ID != first[10+i].Place.ID {
+			t.Errorf("%d: got %s, want %s", i, r.Place.Name, first[10+i].Place.Name)
+		}
+	}
+
+	// pages beyond the ranking are empty
+	if r := p.GetCompletions(context.Background(), places.Query{Text: "Oranien", Offset: 10000}); len(r) != 0 {
+		t.Errorf("got %d, want 0", len(r))
+	}
+}
+
+func TestPlaces_GetCompletionsSpans(t *testing.T) {
+
+	dataProvider := data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	}
+
+	p, err := places.DefaultConfig.NewPlaces(dataProvider)
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		name      string
+		text      string
+		want      string
+		wantSpans []places.Span
+	}{
+		{
+			name:      "Hau (Precomputed)",
+			text:      "Hau",
+			want:      "Hauptstraße",
+			wantSpans: []places.Span{{Start: 0, End: 3}},
+		},
+		{
+			name:      "Platz der L (Prefix)",
+			text:      "Platz der L",
+			want:      "Platz der Luftbrücke",
+			wantSpans: []places.Span{{Start: 0, End: 5}, {Start: 6, End: 9}, {Start: 10, End: 11}},
+		},
+		{
+			name:      "Aachner (Typo)",
+			text:      "Aachner",
+			want:      "Aachener Straße",
+			wantSpans: []places.Span{{Start: 0, End: 4}, {Start: 5, End: 8}},
+		},
+		{
+			name:      "Straße des 17. Juni (Exact)",
+			text:      "Straße des 17. Juni",
+			want:      "Straße des 17. Juni",
+			wantSpans: []places.Span{{Start: 0, End: 6}, {Start: 7, End: 10}, {Start: 11, End: 13}, {Start: 15, End: 19}},
+		},
+		{
+			// house numbers have no name (to span)
+			name:      "Elisabeth-Feller-Weg 10 (House Number)",
+			text:      "Elisabeth-Feller-Weg 10",
+			want:      "",
+			wantSpans: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := p.GetCompletions(context.Background(), places.Query{Text: tt.text})
+			if len(r) < 1 {
+				t.Fatalf("got %d, want > 0", len(r))
+			}
+			if r[0].Place.Name != tt.want {
+				t.Fatalf("got %s, want %s", r[0].Place.Name, tt.want)
+			}
+			if fmt.Sprint(r[0].Spans) != fmt.Sprint(tt.wantSpans) {
+				t.Errorf("got %v, want %v", r[0].Spans, tt.wantSpans)
+			}
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsSpansDecomposed(t *testing.T) {
+
+	// names with decomposed umlauts (i.e. "u" followed by a combining diaeresis)
+	p, err := places.DefaultConfig.NewPlaces(namesProvider{"Mu\u0308ggelseedamm", "Platz der Luftbru\u0308cke"})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		text      string
+		wantSpans []places.Span
+	}{
+		{text: "Müggel", wantSpans: []places.Span{{Start: 0, End: 7}}},
+		{text: "Mueggelseedam", wantSpans: []places.Span{{Start: 0, End: 13}}},
+		{text: "Platz der Luftbrüc", wantSpans: []places.Span{{Start: 0, End: 5}, {Start: 6, End: 9}, {Start: 10, End: 19}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			r := p.GetCompletions(context.Background(), places.Query{Text: tt.text})
+			if len(r) < 1 {
+				t.Fatalf("got %d, want > 0", len(r))
+			}
+			if fmt.Sprint(r[0].Spans) != fmt.Sprint(tt.wantSpans) {
+				t.Errorf("got %v, want %v", r[0].Spans, tt.wantSpans)
+			}
+		})
+	}
+}
+
+func TestSanitizeString(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "Köpenicker Straße", want: "koepenickerstrasse"},
+		{input: "Koepenicker Strasse", want: "koepenickerstrasse"},
+		{input: "KÖPENICKER STRASSE", want: "koepenickerstrasse"},
+		{input: "Ko\u0308penicker Straße", want: "koepenickerstrasse"}, // decomposed umlaut
+		{input: "Müggelseedamm", want: "mueggelseedamm"},
+		{input: "Am Kölnischen Park", want: "amkoelnischenpark"},
+		{input: "Rue Montesquieu-Fezensac", want: "ruemontesquieufezensac"},
+		{input: "Café Liège", want: "cafeliege"},
+		{input: "Straße des 17. Juni", want: "strassedes17juni"},
+		{input: "Platz des 9. November 1989", want: "platzdes9november1989"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := places.SanitizeString(tt.input); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsFolded(t *testing.T) {
+
+	dataProvider := data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	}
+
+	p, err := places.DefaultConfig.NewPlaces(dataProvider)
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		name      string
+		text      string
+		want      string
+		wantSpans []places.Span
+	}{
+		{
+			name:      "Koepenicker Strasse (Transcribed)",
+			text:      "Koepenicker Strasse",
+			want:      "Köpenicker Straße",
+			wantSpans: []places.Span{{Start: 0, End: 10}, {Start: 11, End: 17}},
+		},
+		{
+			name:      "Koepenick (Transcribed District)",
+			text:      "Koepenick",
+			want:      "Köpenick",
+			wantSpans: []places.Span{{Start: 0, End: 8}},
+		},
+		{
+			name:      "Koepenicker Str (Transcribed Prefix)",
+			text:      "Koepenicker Str",
+			want:      "Köpenicker Straße",
+			wantSpans: []places.Span{{Start: 0, End: 10}, {Start: 11, End: 14}},
+		},
+		{
+			name:      "Platz der Luftbrucke (Missing Umlaut)",
+			text:      "Platz der Luftbrucke",
+			want:      "Platz der Luftbrücke",
+			wantSpans: []places.Span{{Start: 0, End: 5}, {Start: 6, End: 9}, {Start: 10, End: 20}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := p.GetCompletions(context.Background(), places.Query{Text: tt.text})
+			if len(r) < 1 {
+				t.Fatalf("got %d, want > 0", len(r))
+			}
+			if r[0].Place.Name != tt.want {
+				t.Fatalf("got %s, want %s", r[0].Place.Name, tt.want)
+			}
+			if fmt.Sprint(r[0].Spans) != fmt.Sprint(tt.wantSpans) {
+				t.Errorf("got %v, want %v", r[0].Spans, tt.wantSpans)
+			}
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsNumbered(t *testing.T) {
+
+	dataProvider := data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(NumberedPlacesCSV),
+	}
+
+	p, err := places.DefaultConfig.NewPlaces(dataProvider)
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		text            string
+		want            string
+		wantHouseNumber string
+	}{
+		{text: "Straße des 17. Juni", want: "Straße des 17. Juni"},
+		{text: "Strasse des 17 Juni", want: "Straße des 17. Juni"},
+		{text: "Straße des 17", want: "Straße des 17. Juni"},
+		{text: "Straße des 17. Juni 135", want: "Straße des 17. Juni", wantHouseNumber: "135"},
+		{text: "Platz des 9. November 1989", want: "Platz des 9. November 1989"},
+		{text: "Platz des 9", want: "Platz des 9. November 1989"},
+		{text: "Straße 70", want: "Straße 70"},
+		{text: "Straße 645", want: "Straße 645"},
+		{text: "Straße 70 12", want: "Straße 70", wantHouseNumber: "12"},
+		{text: "Allee der Kosmonauten 2 a", want: "Allee der Kosmonauten", wantHouseNumber: "2a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			r := p.GetCompletions(context.Background(), places.Query{Text: tt.text})
+			if len(r) < 1 {
+				t.Fatalf("got %d, want > 0", len(r))
+			}
+			got := r[0].Place
+			if tt.wantHouseNumber != "" {
+				if got.Class != places.HouseNumberClass || got.HouseNumber != tt.wantHouseNumber {
+					t.Fatalf("got %s (%s), want house number %s", got.Name, got.HouseNumber, tt.wantHouseNumber)
+				}
+				got = got.Street
+			}
+			if got.Name != tt.want {
+				t.Errorf("got %s, want %s", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestHolder_Reload(t *testing.T) {
+
+	h, err := places.DefaultConfig.NewHolder(data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	// learn relevance
+	old := h.Places()
+	for i := 0; i < 3; i++ {
+		old.GetCompletions(context.Background(), places.Query{Text: "Aalemannufer"})
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// reload with a street added (and a broken dataset)
+	err = h.Reload(data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV + "10,,Oranienstraße,,,10999,1000,52.50,13.41\n"),
+	})
+	if err == nil {
+		t.Fatalf("got no error, want one (unknown postcode)")
+	}
+	if h.Places() != old {
+		t.Fatalf("got new places, want the old ones to be kept")
+	}
+
+	// reload with a street added
+	err = h.Reload(data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV + "10,,Oranienstraße,,,10961,1000,52.50,13.41\n"),
+	})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to reload places: %w", err))
+	}
+	p := h.Places()
+	if p == old {
+		t.Fatalf("got old places, want new ones")
+	}
+
+	// the new street is known
+	if r := p.GetCompletions(context.Background(), places.Query{Text: "Oranienstraße"}); len(r) == 0 || r[0].Place.ID != 10 {
+		t.Errorf("got %v, want Oranienstraße", r)
+	}
+
+	// relevance was carried over
+	if got := p.GetPlace(context.Background(), 3, "").Relevance; got != 3 {
+		t.Errorf("got relevance %d, want 3", got)
+	}
+
+	// the replaced places keep serving requests
+	if r := old.GetCompletions(context.Background(), places.Query{Text: "Aalemannufer"}); len(r) == 0 || r[0].Place.ID != 3 {
+		t.Errorf("got %v, want Aalemannufer", r)
+	}
+}
+
+func TestHolder_ReloadGoroutines(t *testing.T) {
+
+	provider := func() places.Provider {
+		return data.CSVProvider{
+			DistrictsReader: strings.NewReader(DistrictsCSV),
+			PlacesReader:    strings.NewReader(PlacesCSV),
+		}
+	}
+	h, err := places.DefaultConfig.NewHolder(provider())
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+	time.Sleep(100 * time.Millisecond)
+	before := runtime.NumGoroutine()
+
+	// reload (while serving requests)
+	for i := 0; i < 20; i++ {
+		h.Places().GetCompletions(context.Background(), places.Query{Text: "Aalemannufer"})
+		h.Places().GetCompletions(context.Background(), places.Query{Text: "Hauptstr"})
+		if err := h.Reload(provider()); err != nil {
+			t.Fatal(fmt.Errorf("failed to reload places: %w", err))
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// the caches of replaced places are closed (i.e. their goroutines are gone)
+	if after := runtime.NumGoroutine(); after > before+2 {
+		t.Errorf("got %d goroutines after 20 reloads, want at most %d", after, before+2)
+	}
+}
+
+func TestPlaces_SaveRelevance(t *testing.T) {
+
+	newPlaces := func() *places.Places {
+		p, err := places.DefaultConfig.NewPlaces(data.CSVProvider{
+			DistrictsReader: strings.NewReader(DistrictsCSV),
+			PlacesReader:    strings.NewReader(PlacesCSV),
+		})
+		if err != nil {
+			t.Fatal(fmt.Errorf("failed to init places: %w", err))
+		}
+		return p
+	}
+
+	// learn relevance
+	p := newPlaces()
+	for i := 0; i < 2; i++ {
+		p.GetCompletions(context.Background(), places.Query{Text: "Aalemannufer"})
+	}
+	p.GetCompletions(context.Background(), places.Query{Text: "Köppelweg"})
+	time.Sleep(100 * time.Millisecond)
+
+	// persist relevance (twice, i.e. replacing the previous snapshot)
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "relevance.csv")
+	for i := 0; i < 2; i++ {
+		if err := p.SaveRelevance(fileName); err != nil {
+			t.Fatal(fmt.Errorf("failed to save relevance: %w", err))
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d files, want 1 (no temporary files left)", len(entries))
+	}
+
+	// restore relevance
+	restored := newPlaces()
+	if err := restored.LoadRelevance(fileName); err != nil {
+		t.Fatal(fmt.Errorf("failed to load relevance: %w", err))
+	}
+	for id, want := range map[int64]uint64{3: 2, 8: 1, 4: 0} {
+		if got := restored.GetPlace(context.Background(), id, "").Relevance; got != want {
+			t.Errorf("%d: got relevance %d, want %d", id, got, want)
+		}
+	}
+
+	// corrupt snapshots are rejected
+	if err := restored.ReadRelevance(strings.NewReader("id,relevance\n3,x\n")); err == nil {
+		t.Errorf("got no error, want one")
+	}
+}
+
+func TestPlaces_RelevanceHalfLife(t *testing.T) {
+
+	// Hauptstraße (4) had 10 hits 10 half-lives ago, Hauptstraße (5) had 1 hit now
+	halfLife := time.Hour
+	now := float64(time.Since(time.Unix(0, 0))) / float64(halfLife)
+	snapshot := fmt.Sprintf("id,relevance,score\n4,10,%f\n5,1,%f\n", math.Log2(10)+now-10, now)
+
+	tests := []struct {
+		name     string
+		halfLife time.Duration
+		wantID   int64
+	}{
+		{name: "no decay", halfLife: 0, wantID: 4},
+		{name: "decay", halfLife: halfLife, wantID: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := *places.DefaultConfig
+			config.RelevanceHalfLife = tt.halfLife
+			p, err := config.NewPlaces(data.CSVProvider{
+				DistrictsReader: strings.NewReader(DistrictsCSV),
+				PlacesReader:    strings.NewReader(PlacesCSV),
+			})
+			if err != nil {
+				t.Fatal(fmt.Errorf("failed to init places: %w", err))
+			}
+			if err := p.ReadRelevance(strings.NewReader(snapshot)); err != nil {
+				t.Fatal(fmt.Errorf("failed to read relevance: %w", err))
+			}
+			r := p.GetCompletions(context.Background(), places.Query{Text: "Hauptstr"})
+			if len(r) < 2 {
+				t.Fatalf("got %d, want >= 2", len(r))
+			}
+			if r[0].Place.ID != tt.wantID {
+				t.Errorf("got %d, want %d", r[0].Place.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+// TestPlaces_Concurrency stresses concurrent lookups and relevance updates (of
+// prefix completions) and should be run with the race detector (go test -race).
+func TestPlaces_Concurrency(t *testing.T) {
+
+	p, err := places.DefaultConfig.NewPlaces(data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	// exact matches (which update relevance), prefixes, typos and paginated queries
+	queries := []places.Query{
+		{Text: "Aalemannufer"},
+		{Text: "Hauptstraße"},
+		{Text: "Köppelweg"},
+		{Text: "H"},
+		{Text: "Ha"},
+		{Text: "Haupt"},
+		{Text: "Kopenicker"},
+		{Text: "Aa"},
+		{Text: "Haupt", Limit: 1, Offset: 1},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				q := queries[(g+i)%len(queries)]
+				for _, r := range p.GetCompletions(context.Background(), q) {
+					if r.Place == nil {
+						t.Errorf("%s: got result without place", q.Text)
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			_ = p.Metrics()
+			if err := p.WriteRelevance(io.Discard); err != nil {
+				t.Errorf("failed to write relevance: %v", err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	// wait for pending relevance updates, then exact matches must still come first
+	time.Sleep(100 * time.Millisecond)
+	for _, text := range []string{"Aalemannufer", "Köppelweg"} {
+		r := p.GetCompletions(context.Background(), places.Query{Text: text})
+		if len(r) == 0 || r[0].Place.Name != text {
+			t.Errorf("%s: got %v, want it first", text, r)
+		}
+	}
+}
+
+func TestPlaces_Ranker(t *testing.T) {
+
+	// a street and a location with names of equal length
+	placesCSV := `
+id,type,name,street_id,house_number,postcode,length,lat,lon
+1,,Hauptstraße,,,10961,500,52.49,13.35
+2,,Aachener Straße,,,10961,100,52.48010401206288,13.318894891444728
+4294967297,station,Hauptzollamt,1,1,10961,,52.5251,13.3694
+`
+	locationsFirst := places.RankerFunc(func(i, j *places.Result) bool {
+		if i.Place.Class != j.Place.Class {
+			return i.Place.Class == places.LocationClass
+		}
+		return i.Place.SimpleName < j.Place.SimpleName
+	})
+
+	tests := []struct {
+		name    string
+		weights string
+		ranker  places.Ranker
+		text    string
+		want    string
+	}{
+		{name: "default prefix", text: "Ha", want: "Hauptstraße"},
+		{name: "default", text: "Haupt", want: "Hauptstraße"},
+		{name: "weighted prefix", weights: "location=5", text: "Ha", want: "Hauptzollamt"},
+		{name: "weighted", weights: "location=5", text: "Haupt", want: "Hauptzollamt"},
+		{name: "weighted street", weights: "street=5", text: "Haupt", want: "Hauptstraße"},
+		{name: "custom prefix", ranker: locationsFirst, text: "Ha", want: "Hauptzollamt"},
+		{name: "custom", ranker: locationsFirst, text: "Haupt", want: "Hauptzollamt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := *places.DefaultConfig
+			config.Ranker = tt.ranker
+			if tt.weights != "" {
+				weights, err := places.ParseRankingWeights(tt.weights)
+				if err != nil {
+					t.Fatal(fmt.Errorf("failed to parse ranking weights: %w", err))
+				}
+				config.RankingWeights = weights
+			}
+			p, err := config.NewPlaces(data.CSVProvider{
+				DistrictsReader: strings.NewReader(DistrictsCSV),
+				PlacesReader:    strings.NewReader(placesCSV),
+			})
+			if err != nil {
+				t.Fatal(fmt.Errorf("failed to init places: %w", err))
+			}
+			r := p.GetCompletions(context.Background(), places.Query{Text: tt.text})
+			if len(r) == 0 {
+				t.Fatalf("got no results, want %s", tt.want)
+			}
+			if r[0].Place.Name != tt.want {
+				t.Errorf("got %s, want %s", r[0].Place.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRankingWeights(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    places.RankingWeights
+		wantErr bool
+	}{
+		{input: "", want: places.DefaultRankingWeights},
+		{input: "Location=5, distance=-2", want: func() places.RankingWeights {
+			w := places.DefaultRankingWeights
+			w.Location = 5
+			w.Distance = -2
+			return w
+		}()},
+		{input: "location", wantErr: true},
+		{input: "unknown=1", wantErr: true},
+		{input: "street=high", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := places.ParseRankingWeights(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsPostcode(t *testing.T) {
+
+	p, err := places.DefaultConfig.NewPlaces(data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	// postcode entries
+	r := p.GetCompletions(context.Background(), places.Query{Text: "1"})
+	if len(r) != 2 || r[0].District.Postcode != "10961" || r[1].District.Postcode != "12524" {
+		t.Fatalf("got %v, want postcodes 10961 and 12524", r)
+	}
+	r = p.GetCompletions(context.Background(), places.Query{Text: "109"})
+	if len(r) != 1 {
+		t.Fatalf("got %d, want 1", len(r))
+	}
+	if r[0].Place != nil || r[0].Match != places.PostcodeMatch || r[0].Distance != 2 {
+		t.Errorf("got %+v, want postcode entry at distance 2", r[0])
+	}
+	if r[0].District.District != "Friedrichshain-Kreuzberg" {
+		t.Errorf("got %s, want Friedrichshain-Kreuzberg", r[0].District.District)
+	}
+
+	// complete postcodes add places in that postcode
+	r = p.GetCompletions(context.Background(), places.Query{Text: "12524"})
+	if len(r) != 5 || r[0].District == nil {
+		t.Fatalf("got %d, want postcode entry and 4 places", len(r))
+	}
+	for _, result := range r[1:] {
+		if result.Place.District.Postcode != "12524" {
+			t.Errorf("got %s in %s, want 12524", result.Place.Name, result.Place.District.Postcode)
+		}
+	}
+
+	// postcode entries are subject to class and type restrictions
+	r = p.GetCompletions(context.Background(), places.Query{Text: "109", Classes: []places.Class{places.StreetClass}})
+	if len(r) != 0 {
+		t.Errorf("got %v, want no postcode entries for streets", r)
+	}
+	r = p.GetCompletions(context.Background(), places.Query{Text: "109", Classes: []places.Class{places.DistrictClass}})
+	if len(r) != 1 || r[0].District == nil {
+		t.Errorf("got %v, want postcode entry for districts", r)
+	}
+	r = p.GetCompletions(context.Background(), places.Query{Text: "12524", Classes: []places.Class{places.StreetClass}})
+	if len(r) != 3 {
+		t.Fatalf("got %d, want 3 streets", len(r))
+	}
+	for _, result := range r {
+		if result.Place == nil || result.Place.Class != places.StreetClass {
+			t.Errorf("got %+v, want street", result)
+		}
+	}
+	r = p.GetCompletions(context.Background(), places.Query{Text: "12524", Types: []string{"restaurant"}})
+	if len(r) != 1 || r[0].Place == nil || r[0].Place.Name != "Strandlust" {
+		t.Errorf("got %v, want Strandlust", r)
+	}
+
+	// the postcode restricts the search
+	tests := []struct {
+		name  string
+		query places.Query
+		want  []int64
+	}{
+		{name: "10961", query: places.Query{Text: "10961 Haupt"}, want: []int64{4}},
+		{name: "12524", query: places.Query{Text: "12524 Hauptstraße"}, want: []int64{5}},
+		{name: "conflict", query: places.Query{Text: "12524 Haupt", Postcodes: []string{"10961"}}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := p.GetCompletions(context.Background(), tt.query)
+			var got []int64
+			for _, result := range r {
+				got = append(got, result.Place.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaces_CompleteHouseNumber(t *testing.T) {
+
+	placesCSV := `
+id,type,name,street_id,house_number,postcode,length,lat,lon
+1,,Oranienstraße,,,10961,1000,52.5018,13.4167
+4294967297,bar,Luzia,1,34,10961,,52.5011,13.4205
+8589934593,,,1,10,10961,,52.5021,13.4150
+8589934594,,,1,3a,10961,,52.5022,13.4151
+8589934595,,,1,3,10961,,52.5023,13.4152
+8589934596,,,1,2,10961,,52.5024,13.4153
+8589934597,,,1,3B,10961,,52.5025,13.4154
+`
+	p, err := places.DefaultConfig.NewPlaces(data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(placesCSV),
+	})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		name   string
+		id     int64
+		prefix string
+		want   []string
+	}{
+		{name: "all", id: 1, prefix: "", want: []string{"2", "3", "3a", "3b", "10"}},
+		{name: "prefix", id: 1, prefix: "3", want: []string{"3", "3a", "3b"}},
+		{name: "suffix", id: 1, prefix: "3 B", want: []string{"3b"}},
+		{name: "none", id: 1, prefix: "5", want: []string{}},
+		{name: "location", id: 4294967297, prefix: "1", want: []string{"10"}},
+		{name: "house number", id: 8589934593, prefix: "", want: nil},
+		{name: "unknown", id: 2, prefix: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			houses := p.CompleteHouseNumber(context.Background(), tt.id, tt.prefix)
+			if (houses == nil) != (tt.want == nil) {
+				t.Fatalf("got %v, want %v", houses, tt.want)
+			}
+			got := make([]string, len(houses))
+			for i, house := range houses {
+				got[i] = house.HouseNumber
+				if house.Lat == 0 || house.Lon == 0 {
+					t.Errorf("%s: got no coordinates", house.HouseNumber)
+				}
+			}
+			if tt.want != nil && fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHouseNumber(t *testing.T) {
+	tests := []struct {
+		input    string
+		want     string
+		contains []string
+		excludes []string
+		wantErr  bool
+	}{
+		{input: "3", want: "3", contains: []string{"3"}, excludes: []string{"3a", "4"}},
+		{input: "3 A", want: "3a", contains: []string{"3a", "3A"}, excludes: []string{"3", "3b"}},
+		{input: "3-5", want: "3-5", contains: []string{"3", "4", "4a", "5", "3-4"}, excludes: []string{"2", "5a", "6", "4-6"}},
+		{input: "5-3", want: "3-5", contains: []string{"4"}},
+		{input: "3a-c", want: "3a-3c", contains: []string{"3b"}, excludes: []string{"3", "3d"}},
+		{input: "12 / 14", want: "12,14", contains: []string{"12", "14"}, excludes: []string{"13"}},
+		{input: "1,3-5", want: "1,3-5", contains: []string{"1", "4"}, excludes: []string{"2"}},
+		{input: "", wantErr: true},
+		{input: "a", wantErr: true},
+		{input: "3+", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			h, err := places.ParseHouseNumber(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if h.String() != tt.want {
+				t.Errorf("got %s, want %s", h.String(), tt.want)
+			}
+			for _, s := range append(tt.contains, tt.excludes...) {
+				o, errO := places.ParseHouseNumber(s)
+				if errO != nil {
+					t.Fatal(errO)
+				}
+				want := !containsString(tt.excludes, s)
+				if got := h.Contains(o); got != want {
+					t.Errorf("%s contains %s: got %t, want %t", tt.want, s, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlaces_GetPlaceHouseNumberRange(t *testing.T) {
+
+	placesCSV := `
+id,type,name,street_id,house_number,postcode,length,lat,lon
+1,,Oranienstraße,,,10961,1000,52.5018,13.4167
+8589934593,,,1,3-5,10961,,52.5021,13.4150
+8589934594,,,1,4A,10961,,52.5022,13.4151
+8589934595,,,1,7,10961,,52.5023,13.4152
+`
+	p, err := places.DefaultConfig.NewPlaces(data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(placesCSV),
+	})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		houseNumber string
+		wantID      int64
+	}{
+		{houseNumber: "4", wantID: 8589934593},
+		{houseNumber: "4 a", wantID: 8589934594},
+		{houseNumber: "3-5", wantID: 8589934593},
+		{houseNumber: "7", wantID: 8589934595},
+		{houseNumber: "6", wantID: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.houseNumber, func(t *testing.T) {
+
+			// via place ID
+			var gotID int64
+			if house := p.GetPlace(context.Background(), 1, tt.houseNumber); house != nil {
+				gotID = house.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("got %d, want %d", gotID, tt.wantID)
+			}
+
+			// via completion
+			gotID = 0
+			r := p.GetCompletions(context.Background(), places.Query{Text: "Oranienstraße " + tt.houseNumber})
+			if len(r) > 0 && r[0].Place.Class == places.HouseNumberClass {
+				gotID = r[0].Place.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("got %d via completion, want %d", gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+// containsString returns true, if the given slice contains s.
+func containsString(slice []string, s string) bool {
+	for _, e := range slice {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPlaces_GetPlaceInterpolated(t *testing.T) {
+
+	// odd house numbers along a meridian (0.001 degrees latitude are about 111 meters), 2 on the other side and 9a
+	// next to 9 (i.e. the same number twice)
+	placesCSV := `
+id,type,name,street_id,house_number,postcode,length,lat,lon
+1,,Oranienstraße,,,10961,1000,52.5018,13.4167
+8589934593,,,1,1,10961,,52.500,13.4
+8589934594,,,1,5,10961,,52.504,13.4
+8589934595,,,1,9,10961,,52.508,13.4
+8589934596,,,1,2,10961,,52.500,13.401
+8589934597,,,1,9a,10961,,52.508,13.4
+`
+	p, err := places.DefaultConfig.NewPlaces(data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(placesCSV),
+	})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	tests := []struct {
+		houseNumber      string
+		wantInterpolated bool
+		wantLat          float64
+		wantAccuracy     float64
+		wantNil          bool
+	}{
+		{houseNumber: "5", wantLat: 52.504},
+		{houseNumber: "3", wantInterpolated: true, wantLat: 52.502, wantAccuracy: 222},
+		{houseNumber: "4", wantNil: true},
+		{houseNumber: "11", wantInterpolated: true, wantLat: 52.510, wantAccuracy: 222},
+		{houseNumber: "13", wantInterpolated: true, wantLat: 52.512, wantAccuracy: 444},
+		{houseNumber: "15", wantNil: true},
+		{houseNumber: "9999", wantNil: true},
+		{houseNumber: "3-5", wantNil: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.houseNumber, func(t *testing.T) {
+			house := p.GetPlace(context.Background(), 1, tt.houseNumber)
+			if (house == nil) != tt.wantNil {
+				t.Fatalf("got %v, want nil %t", house, tt.wantNil)
+			}
+			if house == nil {
+				return
+			}
+			if house.Interpolated != tt.wantInterpolated || house.Class != places.HouseNumberClass {
+				t.Errorf("got interpolated %t (%s), want %t", house.Interpolated, house.Class, tt.wantInterpolated)
+			}
+			if math.Abs(house.Lat-tt.wantLat) > 1e-9 || house.Lon != 13.4 {
+				t.Errorf("got %f,%f, want %f,13.4", house.Lat, house.Lon, tt.wantLat)
+			}
+			if math.Abs(house.Accuracy-tt.wantAccuracy) > 1 {
+				t.Errorf("got accuracy %f, want %f", house.Accuracy, tt.wantAccuracy)
+			}
+		})
+	}
+}
+
+func TestPlaces_GetCompletionsDistrict(t *testing.T) {
+
+	p, err := places.DefaultConfig.NewPlaces(data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	})
+	if err != nil {
+		t.Fatal(fmt.Errorf("failed to init places: %w", err))
+	}
+
+	// the places of the data (to compute centroids from)
+	_, placeMap, _, err := data.CSVProvider{
+		DistrictsReader: strings.NewReader(DistrictsCSV),
+		PlacesReader:    strings.NewReader(PlacesCSV),
+	}.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// merged districts are split into their parts
+	if got := p.Metrics().DistrictCount; got != 4 {
+		t.Errorf("got %d districts, want 4", got)
+	}
+
+	for _, text := range []string{"Kreuzberg", "Kreuz", "Köpenick", "Treptow"} {
+		t.Run(text, func(t *testing.T) {
+			r := p.GetCompletions(context.Background(), places.Query{Text: text})
+			if len(r) == 0 || r[0].Place.Class != places.DistrictClass {
+				t.Fatalf("got %v, want a district first", r)
+			}
+			district := r[0].Place
+
+			// districts can be selected by ID
+			selected := p.GetPlace(context.Background(), district.ID, "")
+			if selected != district {
+				t.Fatalf("got %v, want %v", selected, district)
+			}
+
+			// the position is the centroid of the places in the district
+			var lat, lon float64
+			var count int
+			for _, place := range placeMap {
+				if place.District.District == district.District.District {
+					lat += place.Lat
+					lon += place.Lon
+					count += 1
+				}
+			}
+			if count == 0 || math.Abs(lat/float64(count)-district.Lat) > 1e-9 || math.Abs(lon/float64(count)-district.Lon) > 1e-9 {
+				t.Errorf("got %f,%f, want the centroid of %d places", district.Lat, district.Lon, count)
+			}
+		})
+	}
+
+	// the class restriction applies to districts
+	r := p.GetCompletions(context.Background(), places.Query{Text: "Kreuzberg", Classes: []places.Class{places.StreetClass}})
+	for _, result := range r {
+		if result.Place.Class != places.StreetClass {
+			t.Errorf("got %s, want streets only", result.Place.Class)
+		}
+	}
+}
+
+// newRegistry returns a registry of the datasets "berlin" (PlacesCSV) and "numbered" (NumberedPlacesCSV).
+func newRegistry(t *testing.T, config *places.Config) *places.Registry {
+	registry := places.NewRegistry()
+	for name, placesCSV := range map[string]string{"berlin": PlacesCSV, "numbered": NumberedPlacesCSV} {
+		h, err := config.NewHolder(data.CSVProvider{
+			DistrictsReader: strings.NewReader(DistrictsCSV),
+			PlacesReader:    strings.NewReader(placesCSV),
+		})
+		if err != nil {
+			t.Fatal(fmt.Errorf("failed to init places: %w", err))
+		}
+		if err := registry.Add(name, h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return registry
+}
+
+func TestRegistry_GetCompletions(t *testing.T) {
+
+	registry := newRegistry(t, places.DefaultConfig)
+
+	// names must be unique
+	if err := registry.Add("berlin", nil); err == nil {
+		t.Errorf("got nil, want an error for a duplicate dataset")
+	}
+
+	// results are merged across all datasets
+	r, err := registry.GetCompletions(context.Background(), places.Query{Text: "Straße des 17. Juni"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datasets := make(map[string]bool)
+	for _, result := range r {
+		if result.Place.Name == "Straße des 17. Juni" {
+			datasets[result.Dataset] = true
+		}
+	}
+	if !datasets["berlin"] || !datasets["numbered"] {
+		t.Errorf("got %v, want results of both datasets", datasets)
+	}
+
+	// results are restricted to the given datasets
+	r, err = registry.GetCompletions(context.Background(), places.Query{Text: "Straße"}, []string{"numbered"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range r {
+		if result.Dataset != "numbered" {
+			t.Errorf("got %s, want numbered", result.Dataset)
+		}
+	}
+
+	// pages are cut from the merged results
+	all, err := registry.GetCompletions(context.Background(), places.Query{Text: "Straße", Limit: 100}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := registry.GetCompletions(context.Background(), places.Query{Text: "Straße", Limit: 2, Offset: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) < 3 || len(page) != 2 || page[0].Place != all[1].Place || page[1].Place != all[2].Place {
+		t.Errorf("got %v, want results 1-2 of %v", page, all)
+	}
+
+	// unknown datasets fail
+	if _, err := registry.GetCompletions(context.Background(), places.Query{Text: "Straße"}, []string{"nope"}); err == nil {
+		t.Errorf("got nil, want an error for an unknown dataset")
+	}
+
+	// metrics are collected per dataset
+	metrics := registry.Metrics()
+	if metrics["berlin"].QueryCount == 0 || metrics["numbered"].QueryCount == 0 {
+		t.Errorf("got %v, want queries counted per dataset", metrics)
+	}
+}
+
+func TestRegistry_GetCompletionsPostcode(t *testing.T) {
+
+	// few completions per dataset, such that merged postcode entries exceed MinCompletionCount
+	config := *places.DefaultConfig
+	config.MinCompletionCount = 1
+	registry := newRegistry(t, &config)
+
+	for _, text := range []string{"1", "109", "10961", "12524"} {
+		t.Run(text, func(t *testing.T) {
+			r, err := registry.GetCompletions(context.Background(), places.Query{Text: text}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// postcode entries (without a place) of both datasets come first
+			datasets := make(map[string]bool)
+			for i, result := range r {
+				if result.Place != nil {
+					continue
+				}
+				if result.Match != places.PostcodeMatch || result.District == nil {
+					t.Errorf("got %v, want a postcode entry", result)
+				}
+				if i > 0 && r[i-1].Place != nil {
+					t.Errorf("got a postcode entry at %d after a place", i)
+				}
+				datasets[result.Dataset] = true
+			}
+			if len(datasets) == 0 {
+				t.Errorf("got %v, want postcode entries", r)
+			}
+
+			// exact postcode entries are kept beyond MinCompletionCount
+			if len(text) == 5 && (!datasets["berlin"] || !datasets["numbered"]) {
+				t.Errorf("got %v, want postcode entries of both datasets", datasets)
+			}
+		})
+	}
+}
+
+func TestPlaces_DistrictPlaces(t *testing.T) {
+
+	districtsCSV := `
+postcode,district
+10961,Friedrichshain-Kreuzberg
+10963,Tempelhof / Kreuzberg
+10119,Mitte / Pankow
+13187,Pankow
+`
+	placesCSV := `
+id,type,name,street_id,house_number,postcode,length,lat,lon
+1,,Aachener Straße,,,10961,100,52.48,13.31
+2,,Hauptstraße,,,10963,500,52.49,13.35
+3,,Torstraße,,,10119,800,52.52,13.40
+4,,Breite Straße,,,13187,300,52.56,13.41
+5,,Florastraße,,,13187,200,52.57,13.40
+`
+	newPlaces := func(districtsCSV string) *places.Places {
+		p, err := places.DefaultConfig.NewPlaces(data.CSVProvider{
+			DistrictsReader: strings.NewReader(districtsCSV),
+			PlacesReader:    strings.NewReader(placesCSV),
+		})
+		if err != nil {
+			t.Fatal(fmt.Errorf("failed to init places: %w", err))
+		}
+		return p
+	}
+	p := newPlaces(districtsCSV)
+
+	// the places of the data (to compute centroids from)
+	_, placeMap, _, err := data.CSVProvider{
+		DistrictsReader: strings.NewReader(districtsCSV),
+		PlacesReader:    strings.NewReader(placesCSV),
+	}.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each part of the district names is a place (once)
+	want := map[string]int{"Friedrichshain": 1, "Kreuzberg": 2, "Tempelhof": 1, "Mitte": 1, "Pankow": 3}
+	if count := p.Metrics().DistrictCount; count != int32(len(want)) {
+		t.Errorf("got %d, want %d districts", count, len(want))
+	}
+	ids := make(map[string]int64)
+	for name, memberCount := range want {
+		r := p.GetCompletions(context.Background(), places.Query{Text: name, Classes: []places.Class{places.DistrictClass}})
+		var districts []*places.Place
+		for _, result := range r {
+			if result.Place.Name == name {
+				districts = append(districts, result.Place)
+			}
+		}
+		if len(districts) != 1 {
+			t.Fatalf("got %d, want %s once", len(districts), name)
+		}
+		ids[name] = districts[0].ID
+
+		// the position is the centroid of the places in all districts containing the name
+		var lat, lon float64
+		var count int
+		for _, place := range placeMap {
+			for _, part := range strings.FieldsFunc(place.District.District, func(r rune) bool { return r == '-' || r == '/' || r == ' ' }) {
+				if part == name {
+					lat += place.Lat
+					lon += place.Lon
+					count += 1
+				}
+			}
+		}
+		if count != memberCount || math.Abs(lat/float64(count)-districts[0].Lat) > 1e-9 || math.Abs(lon/float64(count)-districts[0].Lon) > 1e-9 {
+			t.Errorf("%s: got %f,%f, want the centroid of %d places", name, districts[0].Lat, districts[0].Lon, memberCount)
+		}
+	}
+
+	// the district of a district place is the one with the most places
+	if got := p.GetPlace(context.Background(), ids["Pankow"], ""); got.District.District != "Pankow" {
+		t.Errorf("got %s, want Pankow", got.District.District)
+	}
+
+	// IDs are stable (regardless of other districts)
+	other := newPlaces(districtsCSV + "12524,Treptow-Köpenick\n10117,Mitte\n")
+	for name, id := range ids {
+		if got := other.GetPlace(context.Background(), id, ""); got == nil || got.Name != name {
+			t.Errorf("got %v, want %s", got, name)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package places
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// postcodeLength is the length of (German) postcodes.
+const postcodeLength = 5
+
+// sortedDistricts returns the given districts sorted by postcode (needed for postcode prefix ranges).
+func sortedDistricts(districtsMap DistrictMap) []*District {
+	districts := make([]*District, 0, len(districtsMap))
+	for _, d := range districtsMap {
+		districts = append(districts, d)
+	}
+	sort.Slice(districts, func(i, j int) bool {
+		return districts[i].Postcode < districts[j].Postcode
+	})
+	return districts
+}
+
+// isNumeric returns true, if s is non-empty and consists of (ASCII) digits only.
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r > unicode.MaxASCII || !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitPostcode splits a leading postcode from the given input. That is, for
+// numeric input (e.g. "109"), splitPostcode returns the input as (prefix of a)
+// postcode and, for input starting with a known postcode (e.g. "10999 Ora"),
+// the postcode and the remaining text. ok is false, if the input starts with
+// neither.
+func (bp *Places) splitPostcode(input string) (postcode string, text string, ok bool) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 || !isNumeric(fields[0]) {
+		return "", "", false
+	}
+	if len(fields) == 1 {
+		return fields[0], "", len(bp.postcodePrefixDistricts(fields[0])) > 0
+	}
+	if _, known := bp.districtsMap[fields[0]]; !known || len(fields[0]) != postcodeLength {
+		return "", "", false
+	}
+	return fields[0], strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), fields[0])), true
+}
+
+// postcodePrefixDistricts returns the districts whose postcode starts with the given prefix.
+func (bp *Places) postcodePrefixDistricts(prefix string) []*District {
+	lo := sort.Search(len(bp.postcodes), func(i int) bool {
+		return bp.postcodes[i].Postcode >= prefix
+	})
+	hi := lo + sort.Search(len(bp.postcodes)-lo, func(i int) bool {
+		return !strings.HasPrefix(bp.postcodes[lo+i].Postcode, prefix)
+	})
+	return bp.postcodes[lo:hi]
+}
+
+// postcodeCompletions returns postcode entries (i.e. results with a district but
+// without a place) for postcodes starting with the given prefix (in order of
+// postcodes). If the prefix is a complete postcode, postcodeCompletions adds
+// the most relevant streets and locations of that postcode. Postcode entries
+// count as districts (without type) wrt. class and type restrictions.
+func (bp *Places) postcodeCompletions(prefix string, query Query) []*Result {
+
+	// the postcode entries (subject to restrictions)
+	entries := query.matchesClass(DistrictClass) && len(query.Types) == 0
+	complete := false
+	var results []*Result
+	for _, d := range bp.postcodePrefixDistricts(prefix) {
+		if len(query.Districts) > 0 && !containsFold(query.Districts, d.District) {
+			continue
+		}
+		if len(query.Postcodes) > 0 && !containsFold(query.Postcodes, d.Postcode) {
+			continue
+		}
+		complete = complete || d.Postcode == prefix
+		if !entries {
+			continue
+		}
+		results = append(results, &Result{
+			Distance: len(d.Postcode) - len(prefix),
+			District: d,
+			Match:    PostcodeMatch,
+			Spans:    []Span{{Start: 0, End: len(prefix)}},
+		})
+	}
+
+	// add the most relevant streets and locations of a complete postcode
+	if complete {
+		query.Postcodes = []string{prefix}
+		places := query.filter(bp.streetsAndLocations)
+		sorted := make([]*Place, len(places))
+		copy(sorted, places)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return bp.relevanceOf(sorted[i]) > bp.relevanceOf(sorted[j])
+		})
+		for _, p := range sorted {
+			results = append(results, &Result{Distance: 0, Place: p, Match: PostcodeMatch})
+		}
+	}
+
+	if query.paginated() {
+		return results[:Min(maxRankingLength, len(results))]
+	}
+	return results[:Min(bp.config.MinCompletionCount, len(results))]
+}
+
+// withPostcode restricts the given query to the given postcode. If the query is
+// already restricted to other postcodes, ok is false (as no place can satisfy
+// both restrictions).
+func (q Query) withPostcode(postcode string) (query Query, ok bool) {
+	if len(q.Postcodes) > 0 && !containsFold(q.Postcodes, postcode) {
+		return q, false
+	}
+	q.Postcodes = []string{postcode}
+	return q, true
+}
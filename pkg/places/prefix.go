@@ -0,0 +1,52 @@
+package places
+
+import "sync/atomic"
+
+// completion represents precomputed results and places (for a given prefix).
+// Once published (see prefixEntry), a completion must not be modified anymore.
+type completion struct {
+
+	// results (i.e. places to suggest for this prefix (only if < MaxPrefixLength)
+	results []*Result
+
+	// places covered by this prefix (if < MaxPrefixLength those are the places in the results)
+	places []*Place
+}
+
+// prefixEntry holds the current completion of a prefix. Readers load the
+// completion without locking, while updates (see updateCompletions) replace it
+// by a new one (copy on write).
+type prefixEntry struct {
+	v atomic.Value
+}
+
+// load returns the current completion.
+func (e *prefixEntry) load() *completion {
+	return e.v.Load().(*completion)
+}
+
+// store replaces the current completion.
+func (e *prefixEntry) store(c *completion) {
+	e.v.Store(c)
+}
+
+// newPrefixTable wraps the given (precomputed) completions into prefix entries.
+// The table itself is never modified afterwards (only its entries are).
+func newPrefixTable(completions map[string]*completion) map[string]*prefixEntry {
+	table := make(map[string]*prefixEntry, len(completions))
+	for prefix, c := range completions {
+		e := &prefixEntry{}
+		e.store(c)
+		table[prefix] = e
+	}
+	return table
+}
+
+// prefixCompletion returns the current completion for the given prefix (if any).
+func (bp *Places) prefixCompletion(prefix string) (*completion, bool) {
+	e, ok := bp.prefixCompletions[prefix]
+	if !ok {
+		return nil, false
+	}
+	return e.load(), true
+}
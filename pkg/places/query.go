@@ -0,0 +1,252 @@
+package places
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Point is a geographic coordinate (in degrees).
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Query describes a completion request.
+type Query struct {
+
+	// Text is the input to complete.
+	Text string
+
+	// Near is an (optional) reference point. If set, results carry their distance
+	// to this point and, all else being equal, closer places are ranked higher.
+	Near *Point
+
+	// Bias is the strength of the proximity bias (see Config.ProximityBias). If nil,
+	// the configured ProximityBias is used.
+	Bias *float64
+
+	// Classes restricts results to places of the given classes (if any).
+	Classes []Class
+
+	// Types restricts results to places of the given types (if any).
+	Types []string
+
+	// Districts restricts results to places in the given districts (if any).
+	Districts []string
+
+	// Postcodes restricts results to places with the given postcodes (if any).
+	Postcodes []string
+
+	// Limit is the number of results to return. If 0, MinCompletionCount results
+	// (plus exact matches) are returned.
+	Limit int
+
+	// Offset is the number of (ranked) results to skip.
+	Offset int
+}
+
+// refined returns true, if the query can't be answered from cached or
+// precomputed results (as those are computed for the plain text and
+// MinCompletionCount only).
+func (q Query) refined() bool {
+	return q.Near != nil || q.filtered() || q.paginated()
+}
+
+// filtered returns true, if the query restricts results.
+func (q Query) filtered() bool {
+	return len(q.Classes) > 0 || len(q.Types) > 0 || len(q.Districts) > 0 || len(q.Postcodes) > 0
+}
+
+// matches returns true, if the given place satisfies all restrictions of the query.
+func (q Query) matches(p *Place) bool {
+
+	if !q.matchesClass(p.Class) {
+		return false
+	}
+
+	if len(q.Types) > 0 && !containsFold(q.Types, p.Type) {
+		return false
+	}
+
+	if len(q.Districts) > 0 && (p.District == nil || !containsFold(q.Districts, p.District.District)) {
+		return false
+	}
+
+	if len(q.Postcodes) > 0 && (p.District == nil || !containsFold(q.Postcodes, p.District.Postcode)) {
+		return false
+	}
+
+	return true
+}
+
+// matchesClass returns true, if the query isn't restricted to classes or the given class is among them.
+func (q Query) matchesClass(class Class) bool {
+	if len(q.Classes) == 0 {
+		return true
+	}
+	for _, c := range q.Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the places matching the restrictions of the query.
+func (q Query) filter(places []*Place) []*Place {
+	if !q.filtered() {
+		return places
+	}
+	var filtered []*Place
+	for _, p := range places {
+		if q.matches(p) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// containsFold returns true, if the given slice contains s (ignoring case).
+func containsFold(slice []string, s string) bool {
+	for _, e := range slice {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// candidates returns the places to consider for the given input. That is, all
+// places starting with the input or, if there are none, all streets and
+// locations (if the input is long enough).
+func (bp *Places) candidates(simpleInput string) []*Place {
+
+	runes := []rune(simpleInput)
+	inputLength := len(runes)
+	if inputLength == 0 {
+		return nil
+	}
+
+	// for long inputs, use the places associated with the (max) prefix
+	if inputLength >= bp.config.MaxPrefixLength {
+		if pf, ok := bp.prefixCompletion(string(runes[:bp.config.MaxPrefixLength])); ok {
+			return pf.places
+		}
+
+		// refined rankings (e.g. by proximity or beyond MinCompletionCount) can't be bound via the fuzzy index
+		return bp.streetsAndLocations
+	}
+
+	// for short inputs, use all places starting with the input
+	if places := bp.prefixPlaces(simpleInput); len(places) > 0 {
+		return places
+	}
+
+	// there is no matching prefix, but above MinLev
+	if inputLength >= bp.config.MinLev {
+		return bp.streetsAndLocations
+	}
+
+	return nil
+}
+
+// prefixPlaces returns all streets and locations whose simple name starts with the given prefix.
+func (bp *Places) prefixPlaces(prefix string) []*Place {
+	lo, hi := bp.prefixRange(prefix)
+	return bp.lexicalOrder[lo:hi]
+}
+
+// prefixRange returns the range of streets and locations (in lexicalOrder) whose simple name starts with the given
+// prefix.
+func (bp *Places) prefixRange(prefix string) (int, int) {
+	lo := sort.Search(len(bp.lexicalOrder), func(i int) bool {
+		return bp.lexicalOrder[i].SimpleName >= prefix
+	})
+	hi := lo + sort.Search(len(bp.lexicalOrder)-lo, func(i int) bool {
+		return !strings.HasPrefix(bp.lexicalOrder[lo+i].SimpleName, prefix)
+	})
+	return lo, hi
+}
+
+// isNamePrefix returns true, if the given simple name is a prefix of the simple name of any street or location.
+func (bp *Places) isNamePrefix(simpleName string) bool {
+	return len(bp.prefixPlaces(simpleName)) > 0
+}
+
+// refinedCompletions computes results for queries that can't be answered from
+// cached or precomputed results (see Query.refined).
+func (bp *Places) refinedCompletions(ctx context.Context, simpleInput string, tokens []string, query Query) []*Result {
+
+	// apply restrictions before computing distances (and thus before truncating results)
+	places := query.filter(bp.withInfixCandidates(bp.withTokenCandidates(bp.candidates(simpleInput), tokens), simpleInput))
+
+	// there is no matching prefix among the remaining places, but above MinLev
+	if len(places) == 0 && len([]rune(simpleInput)) >= bp.config.MinLev {
+		places = query.filter(bp.streetsAndLocations)
+	}
+
+	// compute distances for all candidates
+	results, err := bp.computeResults(ctx, places, nil, simpleInput, tokens)
+	if err != nil {
+		return bp.partialCompletions(ctx, simpleInput, query)
+	}
+
+	// fall back to phonetic matches (if needed)
+	results = bp.withPhoneticResults(results, simpleInput, query)
+
+	// compute distances wrt. the reference point (if any)
+	bp.withGeoDistances(results, query)
+
+	results = withSpans(bp.distance, bp.truncateQuery(bp.sortResults(results), simpleInput, query), simpleInput, tokens)
+
+	// update relevance
+	go bp.updateRelevance(results, simpleInput)
+
+	return results
+}
+
+// withGeoDistances sets the distances (and proximity penalties) of the given
+// results wrt. the reference point of the query (if any).
+func (bp *Places) withGeoDistances(results []*Result, query Query) {
+	if query.Near == nil {
+		return
+	}
+	bias := bp.config.ProximityBias
+	if query.Bias != nil {
+		bias = *query.Bias
+	}
+	for _, r := range results {
+		geoDistance := Haversine(query.Near.Lat, query.Near.Lon, r.Place.Lat, r.Place.Lon)
+		r.GeoDistance = &geoDistance
+		r.penalty = bias * math.Log2(1+geoDistance/1000)
+	}
+}
+
+// partialCompletions is the fallback for lookups exceeding the LatencyBudget.
+// partialCompletions only considers places starting with the given input (which
+// are cheap to find) and flags results as partial. If the given context was
+// canceled (i.e. nobody waits for results), partialCompletions returns no
+// results.
+func (bp *Places) partialCompletions(ctx context.Context, simpleInput string, query Query) []*Result {
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return []*Result{}
+	}
+
+	inputLength := len([]rune(simpleInput))
+	results := []*Result{}
+	for _, p := range query.filter(bp.prefixPlaces(simpleInput)) {
+		results = append(results, &Result{
+			Distance: len([]rune(p.SimpleName)) - inputLength,
+			Place:    p,
+			Match:    PrefixMatch,
+			Partial:  true,
+		})
+	}
+	bp.withGeoDistances(results, query)
+
+	return withSpans(bp.distance, bp.truncateQuery(bp.sortResults(results), simpleInput, query), simpleInput, nil)
+}
@@ -0,0 +1,204 @@
+package places
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Ranker ranks results. Less returns true, if result i should be ranked higher
+// than result j (analog to the lesser function in sorting, i.e. higher ranks
+// are sorted to the beginning). Rankers are used concurrently and must thus be
+// safe for concurrent use.
+type Ranker interface {
+	Less(i, j *Result) bool
+}
+
+// RankerFunc is an adapter to allow the use of ordinary functions as Ranker.
+type RankerFunc func(i, j *Result) bool
+
+// Less calls f(i, j).
+func (f RankerFunc) Less(i, j *Result) bool {
+	return f(i, j)
+}
+
+// defaultRanker is the default Ranker (see resultRanking).
+type defaultRanker struct {
+	bp *Places
+}
+
+// Less ranks via resultRanking.
+func (r defaultRanker) Less(i, j *Result) bool {
+	return r.bp.resultRanking(i, j)
+}
+
+// RankingWeights are the weights of the weighted ranker. The weighted ranker
+// ranks results by the weighted sum of the following features (higher sums
+// ranking higher). Results with equal sums are ranked by the default ranking.
+type RankingWeights struct {
+
+	// Distance is the weight of the edit distance (including the proximity penalty).
+	Distance float64 `json:"distance"`
+
+	// Exact is the weight of exact matches (1 for exact matches, 0 otherwise).
+	Exact float64 `json:"exact"`
+
+	// Relevance is the weight of log2(1 + relevance), where relevance is the
+	// (decayed) number of hits.
+	Relevance float64 `json:"relevance"`
+
+	// Coverage is the weight of the fraction of input tokens matched (see TokenMatching).
+	Coverage float64 `json:"coverage"`
+
+	// Prefix is the weight of prefix matches (1 for prefix matches, 0 otherwise).
+	Prefix float64 `json:"prefix"`
+
+	// Street is the weight of streets (1 for streets, 0 otherwise).
+	Street float64 `json:"street"`
+
+	// Location is the weight of locations (1 for locations, 0 otherwise).
+	Location float64 `json:"location"`
+
+	// Length is the weight of log2(1 + length) of streets (in meters).
+	Length float64 `json:"length"`
+
+	// GeoDistance is the weight of the distance (in kilometers) to the reference
+	// point of the query (if any).
+	GeoDistance float64 `json:"geoDistance"`
+}
+
+// DefaultRankingWeights are the weights ParseRankingWeights starts from.
+var DefaultRankingWeights = RankingWeights{
+	Distance:  -1,
+	Exact:     10,
+	Relevance: 1,
+	Coverage:  10,
+	Prefix:    1,
+}
+
+// ParseRankingWeights parses comma separated name=weight pairs (e.g.
+// "location=5,distance=-2") into ranking weights. Names are the (case
+// insensitive) JSON names of RankingWeights fields. Weights not given are taken
+// from DefaultRankingWeights.
+func ParseRankingWeights(s string) (*RankingWeights, error) {
+	weights := DefaultRankingWeights
+	fields := map[string]*float64{
+		"distance":    &weights.Distance,
+		"exact":       &weights.Exact,
+		"relevance":   &weights.Relevance,
+		"coverage":    &weights.Coverage,
+		"prefix":      &weights.Prefix,
+		"street":      &weights.Street,
+		"location":    &weights.Location,
+		"length":      &weights.Length,
+		"geodistance": &weights.GeoDistance,
+	}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		nameValue := strings.SplitN(pair, "=", 2)
+		if len(nameValue) != 2 {
+			return nil, fmt.Errorf("failed to parse ranking weight '%s': expected name=weight", pair)
+		}
+		name, value := nameValue[0], nameValue[1]
+		field, ok := fields[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("failed to parse ranking weight '%s': unknown name", pair)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ranking weight '%s': %w", pair, err)
+		}
+		*field = weight
+	}
+	return &weights, nil
+}
+
+// weightedRanker ranks results by the weighted sum of their features (see RankingWeights).
+type weightedRanker struct {
+	bp      *Places
+	weights RankingWeights
+
+	// the time to decay relevance to (see rankerAt), such that scores don't
+	// change while sorting
+	now time.Time
+}
+
+// Less ranks by score and, in case of equal scores, via resultRanking.
+func (r weightedRanker) Less(i, j *Result) bool {
+	si := r.score(i)
+	sj := r.score(j)
+	if si != sj {
+		if si > sj {
+			return true
+		} else {
+			return false
+		}
+	}
+	return r.bp.resultRanking(i, j)
+}
+
+// score computes the weighted sum of the features of the given result.
+func (r weightedRanker) score(result *Result) float64 {
+	w := r.weights
+	p := result.Place
+
+	score := w.Distance*(float64(result.Distance)+result.penalty) + w.Coverage*result.Coverage
+	if result.Distance == 0 {
+		score += w.Exact
+	}
+	if result.Match == PrefixMatch {
+		score += w.Prefix
+	}
+	if w.Relevance != 0 {
+		score += w.Relevance * math.Log2(1+r.bp.currentRelevance(p, r.now))
+	}
+	switch p.Class {
+	case StreetClass:
+		score += w.Street + w.Length*math.Log2(1+float64(p.Length))
+	case LocationClass:
+		score += w.Location
+	}
+	if result.GeoDistance != nil {
+		score += w.GeoDistance * *result.GeoDistance / 1000
+	}
+	return score
+}
+
+// currentRelevance returns the relevance of the given place as of the given
+// time. That is, its decayed relevance score if a RelevanceHalfLife is
+// configured and the number of hits otherwise.
+func (bp *Places) currentRelevance(p *Place, now time.Time) float64 {
+	if bp.config.RelevanceHalfLife > 0 {
+		return math.Exp2(p.logScore() - decayExponent(now, bp.config.RelevanceHalfLife))
+	}
+	return float64(atomic.LoadUint64(&p.Relevance))
+}
+
+// newRanker returns the Ranker for the given configuration. That is, the custom
+// Ranker (if any), the weighted ranker (if there are RankingWeights) or the
+// default ranker.
+func (bp *Places) newRanker() Ranker {
+	if bp.config.Ranker != nil {
+		return bp.config.Ranker
+	}
+	if bp.config.RankingWeights != nil {
+		return weightedRanker{bp: bp, weights: *bp.config.RankingWeights}
+	}
+	return defaultRanker{bp: bp}
+}
+
+// rankerAt returns the ranker to rank results with as of the given time. That
+// is, the weighted ranker decays relevance to the given time (once per ranking,
+// as the order must not change while sorting).
+func (bp *Places) rankerAt(now time.Time) Ranker {
+	if r, ok := bp.ranker.(weightedRanker); ok {
+		r.now = now
+		return r
+	}
+	return bp.ranker
+}
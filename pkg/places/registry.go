@@ -0,0 +1,148 @@
+package places
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Registry holds named datasets (e.g. the places of different cities), each
+// of them served by a Holder. Datasets are added on startup, thus a Registry
+// must not be modified while serving requests.
+type Registry struct {
+
+	// dataset names in order of registration
+	names []string
+
+	// holders mapped by dataset name
+	holders map[string]*Holder
+}
+
+// NewRegistry initializes a new (empty) Registry.
+func NewRegistry() *Registry {
+	return &Registry{holders: make(map[string]*Holder)}
+}
+
+// Add adds a dataset with the given name.
+func (r *Registry) Add(name string, holder *Holder) error {
+	if name == "" {
+		return fmt.Errorf("dataset name must not be empty")
+	}
+	if _, exists := r.holders[name]; exists {
+		return fmt.Errorf("dataset '%s' already exists", name)
+	}
+	r.names = append(r.names, name)
+	r.holders[name] = holder
+	return nil
+}
+
+// Holder returns the holder of the dataset with the given name.
+func (r *Registry) Holder(name string) (*Holder, bool) {
+	h, ok := r.holders[name]
+	return h, ok
+}
+
+// Names returns the names of the datasets (in order of registration).
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.names))
+	copy(names, r.names)
+	return names
+}
+
+// Metrics returns the current metrics of the datasets mapped by dataset name.
+func (r *Registry) Metrics() map[string]Metrics {
+	metrics := make(map[string]Metrics, len(r.holders))
+	for name, h := range r.holders {
+		metrics[name] = h.Places().Metrics()
+	}
+	return metrics
+}
+
+// GetCompletions returns the results for the given query of the given datasets
+// (or of all datasets, if none are given) merged into one ranking. Results
+// carry the name of their dataset (see Result.Dataset). Metrics are collected
+// per dataset.
+func (r *Registry) GetCompletions(ctx context.Context, query Query, names []string) ([]*Result, error) {
+	results, _, err := r.GetCompletionsPartial(ctx, query, names)
+	return results, err
+}
+
+// GetCompletionsPartial is like GetCompletions, but additionally reports
+// whether the results of any dataset are partial (see
+// Places.GetCompletionsPartial).
+func (r *Registry) GetCompletionsPartial(ctx context.Context, query Query, names []string) ([]*Result, bool, error) {
+	if len(names) == 0 {
+		names = r.names
+	}
+	if len(names) == 0 {
+		return []*Result{}, false, nil
+	}
+	placesByName := make([]*Places, len(names))
+	for i, name := range names {
+		h, ok := r.holders[name]
+		if !ok {
+			return nil, false, fmt.Errorf("unknown dataset '%s'", name)
+		}
+		placesByName[i] = h.Places()
+	}
+
+	// each dataset must provide all results up to the requested page
+	datasetQuery := query
+	if query.paginated() {
+		datasetQuery.Offset = 0
+		datasetQuery.Limit = query.Offset + query.limit(placesByName[0].config.MinCompletionCount)
+	}
+
+	// query datasets concurrently
+	resultsByName := make([][]*Result, len(names))
+	partialByName := make([]bool, len(names))
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resultsByName[i], partialByName[i] = placesByName[i].GetCompletionsPartial(ctx, datasetQuery)
+		}(i)
+	}
+	wg.Wait()
+	partial := false
+	for _, p := range partialByName {
+		partial = partial || p
+	}
+
+	// merge results (copies, as results may be shared) tagged with their dataset
+	var merged []*Result
+	for i, results := range resultsByName {
+		for _, result := range results {
+			tagged := *result
+			tagged.Dataset = names[i]
+			merged = append(merged, &tagged)
+		}
+	}
+
+	// rank by the ranking of the first dataset (postcode entries first, as they
+	// have no place to rank by)
+	bp := placesByName[0]
+	ranker := bp.rankerAt(time.Now())
+	sort.SliceStable(merged, func(i, j int) bool {
+		ri, rj := merged[i], merged[j]
+		if ri.Place == nil || rj.Place == nil {
+			if ri.Place == nil && rj.Place == nil {
+				return ri.Distance < rj.Distance
+			}
+			return ri.Place == nil
+		}
+		return ranker.Less(ri, rj)
+	})
+
+	// cut the page or truncate
+	if query.paginated() {
+		if query.Offset >= len(merged) {
+			return []*Result{}, partial, nil
+		}
+		return merged[query.Offset:Min(query.Offset+query.limit(bp.config.MinCompletionCount), len(merged))], partial, nil
+	}
+	return bp.truncate(merged, SanitizeString(query.Text)), partial, nil
+}
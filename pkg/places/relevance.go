@@ -0,0 +1,196 @@
+package places
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// relevanceEntry is the relevance of a place.
+type relevanceEntry struct {
+
+	// the number of hits
+	count uint64
+
+	// the bits of the (log) decayed relevance score (see addScore)
+	score uint64
+}
+
+// relevance returns the (non-zero) relevance of places mapped by place ID.
+func (bp *Places) relevance() map[int64]relevanceEntry {
+	relevance := make(map[int64]relevanceEntry)
+	for id, p := range bp.placesMap {
+		if count := atomic.LoadUint64(&p.Relevance); count > 0 {
+			relevance[id] = relevanceEntry{count: count, score: atomic.LoadUint64(&p.score)}
+		}
+	}
+	return relevance
+}
+
+// restoreRelevance sets the relevance of places (by place ID) and updates
+// prefix completions accordingly. Unknown place IDs are ignored. If decay is
+// enabled but there is no score, hits are considered to have happened now.
+func (bp *Places) restoreRelevance(relevance map[int64]relevanceEntry) {
+
+	// set relevance and collect updated streets, locations and districts by simple name
+	now := time.Now()
+	updated := make(map[string][]*Place)
+	for id, r := range relevance {
+		p, ok := bp.placesMap[id]
+		if !ok {
+			continue
+		}
+		atomic.StoreUint64(&p.Relevance, r.count)
+		score := r.score
+		if score == 0 && r.count > 0 && bp.config.RelevanceHalfLife > 0 {
+			score = scoreBits(math.Log2(float64(r.count)) + decayExponent(now, bp.config.RelevanceHalfLife))
+		}
+		atomic.StoreUint64(&p.score, score)
+		if p.Class == StreetClass || p.Class == LocationClass || p.Class == DistrictClass {
+			updated[p.SimpleName] = append(updated[p.SimpleName], p)
+		}
+	}
+
+	// update prefix completions
+	for _, updatedPlaces := range updated {
+		bp.updateCompletions(updatedPlaces)
+	}
+}
+
+// relevanceHeader is the header of relevance snapshots.
+var relevanceHeader = []string{"id", "relevance", "score"}
+
+// WriteRelevance writes a snapshot of the (non-zero) relevance of places as CSV
+// (i.e. place ID, number of hits and, if decay is enabled, the logarithm of
+// the decayed score) to the given writer. As scores depend on the
+// RelevanceHalfLife, they should only be restored with the same one.
+func (bp *Places) WriteRelevance(w io.Writer) error {
+
+	// sort by place ID (for the sake of reproducible snapshots)
+	relevance := bp.relevance()
+	ids := make([]int64, 0, len(relevance))
+	for id := range relevance {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(relevanceHeader); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		r := relevance[id]
+		score := ""
+		if r.score != 0 {
+			score = strconv.FormatFloat(math.Float64frombits(r.score), 'g', -1, 64)
+		}
+		record := []string{strconv.FormatInt(id, 10), strconv.FormatUint(r.count, 10), score}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// ReadRelevance restores the relevance of places from a snapshot (see
+// WriteRelevance). Relevance of places not existing anymore is ignored.
+// ReadRelevance should be called before serving requests.
+func (bp *Places) ReadRelevance(r io.Reader) error {
+
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1 // the score is optional
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to read relevance: %w", err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	relevance := make(map[int64]relevanceEntry, len(records)-1)
+	for _, record := range records[1:] {
+		if len(record) < 2 || len(record) > len(relevanceHeader) {
+			return fmt.Errorf("failed to read relevance: unexpected record %v", record)
+		}
+		id, errID := strconv.ParseInt(record[0], 10, 64)
+		if errID != nil {
+			return fmt.Errorf("failed to parse place ID '%s': %w", record[0], errID)
+		}
+		count, errCount := strconv.ParseUint(record[1], 10, 64)
+		if errCount != nil {
+			return fmt.Errorf("failed to parse relevance '%s': %w", record[1], errCount)
+		}
+		entry := relevanceEntry{count: count}
+		if len(record) > 2 && record[2] != "" {
+			score, errScore := strconv.ParseFloat(record[2], 64)
+			if errScore != nil {
+				return fmt.Errorf("failed to parse score '%s': %w", record[2], errScore)
+			}
+			entry.score = scoreBits(score)
+		}
+		relevance[id] = entry
+	}
+	bp.restoreRelevance(relevance)
+
+	return nil
+}
+
+// SaveRelevance writes a snapshot of the relevance of places to the given file
+// (see WriteRelevance). The snapshot is written to a temporary file first and
+// then renamed, thus the file either contains the previous or the new snapshot.
+func (bp *Places) SaveRelevance(fileName string) error {
+
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name()) // fails after the rename, which is fine
+	}()
+
+	w := bufio.NewWriter(tmp)
+	if err := bp.WriteRelevance(w); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write relevance: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write relevance: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync '%s': %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close '%s': %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), fileName); err != nil {
+		return fmt.Errorf("failed to rename '%s': %w", tmp.Name(), err)
+	}
+
+	return nil
+}
+
+// LoadRelevance restores the relevance of places from the given file (see
+// ReadRelevance and SaveRelevance).
+func (bp *Places) LoadRelevance(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	return bp.ReadRelevance(file)
+}
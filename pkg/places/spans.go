@@ -0,0 +1,173 @@
+package places
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Span is a (half-open) range of runes in the name of a place.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// nameOffsets returns the range of runes in the given name for each rune of
+// the simple name (see SanitizeString) derived from it. As simple names are
+// derived from the NFC form of names, a rune composed of several runes in the
+// name (e.g. "o" followed by a combining diaeresis) ranges over all of them.
+func nameOffsets(name string) []Span {
+	var offsets []Span
+	start := 0
+	for len(name) > 0 {
+
+		// the next segment normalizing independently of the rest
+		n := norm.NFC.NextBoundaryInString(name, true)
+		segment := name[:n]
+		name = name[n:]
+		end := start + utf8.RuneCountInString(segment)
+
+		for _, r := range norm.NFC.String(segment) {
+			for range foldRune(r) {
+				offsets = append(offsets, Span{Start: start, End: end})
+			}
+		}
+		start = end
+	}
+	return offsets
+}
+
+// toSpans converts the given (ascending) positions in the simple name of the
+// given place into spans in its name. Positions separated by non-letters (e.g.
+// by blanks or hyphens) result in separate spans.
+func toSpans(p *Place, positions []int) []Span {
+	offsets := nameOffsets(p.Name)
+	var spans []Span
+	for _, pos := range positions {
+		if pos >= len(offsets) {
+			break
+		}
+		offset := offsets[pos]
+		if n := len(spans); n > 0 && offset.Start < spans[n-1].End {
+			continue
+		} else if n > 0 && offset.Start == spans[n-1].End {
+			spans[n-1].End = offset.End
+		} else {
+			spans = append(spans, offset)
+		}
+	}
+	return spans
+}
+
+// positionRange returns the positions from start to end (exclusive).
+func positionRange(start, end int) []int {
+	positions := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		positions = append(positions, i)
+	}
+	return positions
+}
+
+// alignedPositions aligns the given input with a prefix of the given name (i.e.
+// with free trailing runes in the name) and returns the positions of runes in
+// the name being equal to the rune aligned in the input.
+func alignedPositions(input, name []rune) []int {
+
+	// compute the Levenshtein matrix
+	d := make([][]int, len(input)+1)
+	for i := range d {
+		d[i] = make([]int, len(name)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(input); i++ {
+		for j := 1; j <= len(name); j++ {
+			cost := 1
+			if input[i-1] == name[j-1] {
+				cost = 0
+			}
+			d[i][j] = Min(d[i-1][j-1]+cost, Min(d[i-1][j]+1, d[i][j-1]+1))
+		}
+	}
+
+	// the best alignment ends with the shortest prefix of the name with minimal distance
+	j := 0
+	for k := range d[len(input)] {
+		if d[len(input)][k] < d[len(input)][j] {
+			j = k
+		}
+	}
+
+	// trace back (preferring matches)
+	var positions []int
+	i := len(input)
+	for i > 0 && j > 0 {
+		switch {
+		case input[i-1] == name[j-1] && d[i][j] == d[i-1][j-1]:
+			positions = append(positions, j-1)
+			i, j = i-1, j-1
+		case d[i][j] == d[i-1][j-1]+1:
+			i, j = i-1, j-1
+		case d[i][j] == d[i][j-1]+1:
+			j -= 1
+		default:
+			i -= 1
+		}
+	}
+
+	// reverse positions
+	for l, r := 0, len(positions)-1; l < r; l, r = l+1, r-1 {
+		positions[l], positions[r] = positions[r], positions[l]
+	}
+	return positions
+}
+
+// matchSpans returns the spans of the name of the result's place matching the
+// given input (or the given tokens in case of token matches, assigned with the
+// given distance function).
+func matchSpans(distanceOf distanceFunc, r *Result, simpleInput string, tokens []string) []Span {
+	p := r.Place
+	switch r.Match {
+	case PrefixMatch:
+		return toSpans(p, positionRange(0, len([]rune(simpleInput))))
+	case InfixMatch:
+		if index := strings.Index(p.SimpleName, simpleInput); index >= 0 {
+			start := len([]rune(p.SimpleName[:index]))
+			return toSpans(p, positionRange(start, start+len([]rune(simpleInput))))
+		}
+	case TokenMatch:
+
+		// compute the start positions of place tokens in the simple name
+		starts := make([]int, len(p.tokens))
+		start := 0
+		for j, pt := range p.tokens {
+			starts[j] = start
+			start += len([]rune(pt))
+		}
+
+		// align each query token with the place token it matched
+		var positions []int
+		for i, j := range assignTokens(distanceOf, tokens, p) {
+			if j < 0 {
+				continue
+			}
+			for _, pos := range alignedPositions([]rune(tokens[i]), []rune(p.tokens[j])) {
+				positions = append(positions, starts[j]+pos)
+			}
+		}
+		sort.Ints(positions)
+		return toSpans(p, positions)
+	}
+	return toSpans(p, alignedPositions([]rune(simpleInput), []rune(p.SimpleName)))
+}
+
+// withSpans sets the match spans of the given results.
+func withSpans(distanceOf distanceFunc, results []*Result, simpleInput string, tokens []string) []*Result {
+	for _, r := range results {
+		r.Spans = matchSpans(distanceOf, r, simpleInput, tokens)
+	}
+	return results
+}
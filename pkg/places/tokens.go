@@ -0,0 +1,146 @@
+package places
+
+import (
+	"strings"
+	"unicode"
+)
+
+// tokenPrefixLength is the length of the token prefixes used as keys in the token index.
+const tokenPrefixLength = 2
+
+// tokenize splits the given string into sanitized tokens.
+func tokenize(s string) []string {
+	var tokens []string
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if token := SanitizeString(field); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// tokenKey returns the key of the given token in the token index.
+func tokenKey(token string) string {
+	runes := []rune(token)
+	return string(runes[:Min(len(runes), tokenPrefixLength)])
+}
+
+// computeTokenIndex tokenizes the names of the given places and maps places by
+// the prefixes of their tokens.
+func computeTokenIndex(streetsAndLocations []*Place) map[string][]*Place {
+	index := make(map[string][]*Place)
+	for _, p := range streetsAndLocations {
+		p.tokens = tokenize(p.Name)
+		keys := make(map[string]struct{})
+		for _, token := range p.tokens {
+			key := tokenKey(token)
+			if _, ok := keys[key]; !ok {
+				keys[key] = struct{}{}
+				index[key] = append(index[key], p)
+			}
+		}
+	}
+	return index
+}
+
+// queryTokens returns the tokens of the given input (if token matching is enabled).
+func (bp *Places) queryTokens(input string) []string {
+	if !bp.config.TokenMatching {
+		return nil
+	}
+	return tokenize(input)
+}
+
+// withTokenCandidates adds the places sharing a token prefix with the given
+// tokens to the given places.
+func (bp *Places) withTokenCandidates(places []*Place, tokens []string) []*Place {
+
+	// without tokens or if we already consider all places, there is nothing to add
+	if len(tokens) == 0 || len(places) == len(bp.streetsAndLocations) {
+		return places
+	}
+
+	// copy, as places may be shared
+	candidates := make([]*Place, len(places))
+	copy(candidates, places)
+	for _, token := range tokens {
+		candidates = append(candidates, bp.tokenIndex[tokenKey(token)]...)
+	}
+
+	return deDuplicate(candidates)
+}
+
+// tokenTolerance returns the number of typos to tolerate in a token of the given length.
+func tokenTolerance(length int) int {
+	return length / 4
+}
+
+// tokenMatch matches the given query tokens (in any order) against the tokens
+// of the given place. tokenMatch returns the fraction of query tokens matched
+// (within tokenTolerance) and the distance, i.e. the sum of the distances of
+// the matched tokens plus the lengths of the place tokens not matched. As the
+// last query token might be incomplete, it is matched against token prefixes.
+func tokenMatch(distanceOf distanceFunc, tokens []string, p *Place) (float64, int) {
+	_, coverage, distance := matchTokens(distanceOf, tokens, p)
+	return coverage, distance
+}
+
+// assignTokens returns the index of the place token matched by each of the
+// given query tokens (or -1 if not matched), see tokenMatch.
+func assignTokens(distanceOf distanceFunc, tokens []string, p *Place) []int {
+	assigned, _, _ := matchTokens(distanceOf, tokens, p)
+	return assigned
+}
+
+// matchTokens implements tokenMatch and assignTokens.
+func matchTokens(distanceOf distanceFunc, tokens []string, p *Place) ([]int, float64, int) {
+
+	assigned := make([]int, len(tokens))
+	used := make([]bool, len(p.tokens))
+	matched := 0
+	distance := 0
+
+	for i, qt := range tokens {
+		qtRunes := []rune(qt)
+		last := i == len(tokens)-1
+		assigned[i] = -1
+
+		// find the best (unused) place token
+		best, bestDistance, bestRemainder := -1, 0, 0
+		for j, pt := range p.tokens {
+			if used[j] {
+				continue
+			}
+			d := distanceOf(qt, pt)
+			remainder := 0
+			if ptRunes := []rune(pt); last && len(ptRunes) > len(qtRunes) {
+				if dp := distanceOf(qt, string(ptRunes[:len(qtRunes)])); dp < d {
+					d = dp
+					remainder = len(ptRunes) - len(qtRunes)
+				}
+			}
+			if best == -1 || d+remainder < bestDistance+bestRemainder {
+				best, bestDistance, bestRemainder = j, d, remainder
+			}
+		}
+
+		// count the token as matched if within tolerance
+		if best != -1 && bestDistance <= tokenTolerance(len(qtRunes)) {
+			used[best] = true
+			assigned[i] = best
+			matched += 1
+			distance += bestDistance + bestRemainder
+		}
+	}
+
+	// add the lengths of unmatched place tokens
+	for j, pt := range p.tokens {
+		if !used[j] {
+			distance += len([]rune(pt))
+		}
+	}
+
+	return assigned, float64(matched) / float64(len(tokens)), distance
+}